@@ -0,0 +1,200 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const redacted = "***"
+
+var (
+	// writeAccessLog is replaced in tests, the logger discards access records by default
+	writeAccessLog = logger.Access
+
+	defaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+)
+
+type AccessLogConfig struct {
+	// RequestHeaders and ResponseHeaders are the headers written to the record.
+	RequestHeaders  []string
+	ResponseHeaders []string
+	RequestBody     bool
+	ResponseBody    bool
+	// MaxBodyBytes limits the captured bodies, 1024 by default.
+	MaxBodyBytes int
+	// Redact lists header names and JSON or form field names whose values are replaced,
+	// authorization and cookie headers are always redacted.
+	Redact []string
+	Skip   func(c *gin.Context) bool
+}
+
+// AccessLog writes one logger.Access record per request, the trace id is taken from the
+// request context so trace.GinMiddleware should be registered as well.
+func AccessLog(cfg AccessLogConfig) gin.HandlerFunc {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1024
+	}
+	redact := map[string]bool{}
+	for _, name := range append(append([]string{}, defaultRedactHeaders...), cfg.Redact...) {
+		redact[strings.ToLower(name)] = true
+	}
+
+	return func(c *gin.Context) {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		var reqBody []byte
+		if cfg.RequestBody && c.Request.Body != nil {
+			reqBody = captureRequestBody(c.Request, cfg.MaxBodyBytes)
+		}
+		var respBody *captureWriter
+		if cfg.ResponseBody {
+			respBody = &captureWriter{ResponseWriter: c.Writer, limit: cfg.MaxBodyBytes}
+			c.Writer = respBody
+		}
+
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		fields := []logger.LogField{
+			logger.Field("method", c.Request.Method),
+			logger.Field("path", path),
+			logger.Field("status", c.Writer.Status()),
+			logger.Field("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			logger.Field("bytes", size),
+			logger.Field("client_ip", c.ClientIP()),
+			logger.Field("user_agent", c.Request.UserAgent()),
+		}
+		if len(cfg.RequestHeaders) > 0 {
+			fields = append(fields, logger.Field("request_headers", captureHeaders(c.Request.Header, cfg.RequestHeaders, redact)))
+		}
+		if len(cfg.ResponseHeaders) > 0 {
+			fields = append(fields, logger.Field("response_headers", captureHeaders(c.Writer.Header(), cfg.ResponseHeaders, redact)))
+		}
+		if reqBody != nil {
+			fields = append(fields, logger.Field("request_body", redactBody(reqBody, c.ContentType(), redact)))
+		}
+		if respBody != nil {
+			contentType, _, _ := strings.Cut(c.Writer.Header().Get("Content-Type"), ";")
+			fields = append(fields, logger.Field("response_body", redactBody(respBody.buf.Bytes(), contentType, redact)))
+		}
+		if err := c.Errors.Last(); err != nil {
+			fields = append(fields, logger.Field("error", err.Err))
+		}
+		writeAccessLog(c, fields...)
+	}
+}
+
+// captureRequestBody reads up to limit bytes and puts them back in front of the rest of the body
+func captureRequestBody(r *http.Request, limit int) []byte {
+	buf, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	return buf
+}
+
+type captureWriter struct {
+	gin.ResponseWriter
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) capture(b []byte) {
+	if rest := w.limit - w.buf.Len(); rest > 0 {
+		if len(b) > rest {
+			b = b[:rest]
+		}
+		w.buf.Write(b)
+	}
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	if w.buf.Len() < w.limit {
+		w.capture([]byte(s))
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func captureHeaders(header http.Header, names []string, redact map[string]bool) map[string]string {
+	res := make(map[string]string, len(names))
+	for _, name := range names {
+		val := header.Get(name)
+		if val == "" {
+			continue
+		}
+		if redact[strings.ToLower(name)] {
+			val = redacted
+		}
+		res[name] = val
+	}
+	return res
+}
+
+// redactBody returns the captured body with redacted JSON or form fields, JSON that cannot
+// be parsed, e.g. truncated, is left out as it may hold values to redact.
+func redactBody(body []byte, contentType string, redact map[string]bool) string {
+	switch contentType {
+	case gin.MIMEJSON:
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return fmt.Sprintf("<%d bytes of invalid or truncated json>", len(body))
+		}
+		b, _ := json.Marshal(redactJSON(v, redact))
+		return string(b)
+	case gin.MIMEPOSTForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			break
+		}
+		for key := range values {
+			if redact[strings.ToLower(key)] {
+				values[key] = []string{redacted}
+			}
+		}
+		return values.Encode()
+	}
+	return string(body)
+}
+
+func redactJSON(v any, redact map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, val := range v {
+			if redact[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = redactJSON(val, redact)
+			}
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = redactJSON(val, redact)
+		}
+	}
+	return v
+}
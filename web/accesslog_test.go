@@ -0,0 +1,66 @@
+package web
+
+import (
+	"context"
+	"github.com/sakuradon99/gokit/logger"
+	"github.com/sakuradon99/gokit/trace"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func captureAccessLog(t *testing.T) *[]map[string]any {
+	var records []map[string]any
+	writeAccessLog = func(ctx context.Context, kv ...logger.LogField) {
+		record := map[string]any{"trace_id": trace.GetTraceID(ctx)}
+		for _, field := range kv {
+			record[field.Key] = field.Value
+		}
+		records = append(records, record)
+	}
+	t.Cleanup(func() {
+		writeAccessLog = logger.Access
+	})
+	return &records
+}
+
+func Test_AccessLog(t *testing.T) {
+	records := captureAccessLog(t)
+	s := newTestServer(&testUserHandler{})
+	s.accessLog = true
+	s.accessLogHeaders = "X-Tenant-ID, Authorization"
+	s.accessLogBody = true
+	s.accessLogRedact = "password"
+	engine, err := s.engine()
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"u","role":"admin","password":"secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Tenant-ID", "t1")
+	req.Header.Set("User-Agent", "test")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, 1, len(*records))
+	record := (*records)[0]
+	assert.Equal(t, w.Header().Get("X-Trace-ID"), record["trace_id"])
+	assert.NotEmpty(t, record["trace_id"])
+	assert.Equal(t, http.MethodPost, record["method"])
+	assert.Equal(t, "/users", record["path"])
+	assert.Equal(t, http.StatusOK, record["status"])
+	assert.Equal(t, w.Body.Len(), record["bytes"])
+	assert.Equal(t, "test", record["user_agent"])
+	assert.Equal(t, map[string]string{"X-Tenant-ID": "t1", "Authorization": "***"}, record["request_headers"])
+	assert.JSONEq(t, `{"name":"u","role":"admin","password":"***"}`, record["request_body"].(string))
+	assert.JSONEq(t, w.Body.String(), record["response_body"].(string))
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/x", nil))
+	assert.Equal(t, 2, len(*records))
+	assert.Equal(t, "/users/:id", (*records)[1]["path"])
+	assert.Equal(t, http.StatusBadRequest, (*records)[1]["status"])
+	assert.NotNil(t, (*records)[1]["error"])
+}
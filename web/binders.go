@@ -1,106 +1,316 @@
 package web
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
 	"reflect"
+	"strings"
 )
 
 type binder interface {
 	Bind(c *gin.Context, param reflect.Value) error
 }
 
-type pathBinder struct {
+var sourceTags = []string{"path", "query", "form", "header", "cookie", "request", "file", "files"}
+
+// fieldSource returns the source a param field is bound from and the name it is bound by.
+func fieldSource(field reflect.StructField) (string, string) {
+	for _, tag := range sourceTags {
+		name := field.Tag.Get(tag)
+		if name == "" {
+			continue
+		}
+		switch tag {
+		case "request":
+			return SourceBody, name
+		case "files":
+			return SourceFile, name
+		default:
+			return tag, name
+		}
+	}
+	return "", ""
+}
+
+type valueBinder struct {
 	field      int
-	path       string
+	kind       reflect.Kind
+	source     string
+	name       string
 	defaultVal string
+	sep        string
+	layout     string
 }
 
-func (b *pathBinder) Bind(c *gin.Context, param reflect.Value) error {
-	val := c.Param(b.path)
+func newValueBinder(i int, field reflect.StructField, source, name string) (valueBinder, error) {
+	var err error
+	switch field.Type.Kind() {
+	case reflect.Slice:
+		err = checkValType(field.Type.Elem())
+	case reflect.Map:
+		if source != SourceQuery && source != SourceForm {
+			err = fmt.Errorf("unsupported type %s", field.Type)
+		} else if err = checkValType(field.Type.Key()); err == nil {
+			err = checkValType(field.Type.Elem())
+		}
+	default:
+		err = checkValType(field.Type)
+	}
+	if err != nil {
+		return valueBinder{}, fmt.Errorf("%s field %s: %w", source, field.Name, err)
+	}
+
+	b := valueBinder{
+		field:      i,
+		kind:       field.Type.Kind(),
+		source:     source,
+		name:       name,
+		defaultVal: field.Tag.Get("default"),
+		sep:        field.Tag.Get("sep"),
+		layout:     field.Tag.Get("layout"),
+	}
+	if err = b.checkDefault(field.Type); err != nil {
+		return valueBinder{}, fmt.Errorf("%s field %s: invalid default %q: %w", source, field.Name, b.defaultVal, err)
+	}
+	return b, nil
+}
+
+func (b *valueBinder) checkDefault(rtp reflect.Type) error {
+	if b.defaultVal == "" || rtp.Kind() == reflect.Map {
+		return nil
+	}
+	if rtp.Kind() != reflect.Slice {
+		return setVal(reflect.New(rtp).Elem(), b.defaultVal, b.layout)
+	}
+	for _, val := range b.split([]string{b.defaultVal}) {
+		if err := setVal(reflect.New(rtp.Elem()).Elem(), val, b.layout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *valueBinder) bind(param reflect.Value, vals []string) error {
+	fieldValue := param.Field(b.field)
+	if b.kind == reflect.Slice {
+		return b.bindSlice(fieldValue, vals)
+	}
+
+	val := ""
+	if len(vals) > 0 {
+		val = vals[0]
+	}
 	if val == "" {
 		val = b.defaultVal
 	}
-	err := setVal(param.Field(b.field), val)
-	return err
+	if err := setVal(fieldValue, val, b.layout); err != nil {
+		return b.error(val, err)
+	}
+	return nil
+}
+
+func (b *valueBinder) bindSlice(fieldValue reflect.Value, vals []string) error {
+	vals = b.split(vals)
+	if len(vals) == 0 && b.defaultVal != "" {
+		vals = b.split([]string{b.defaultVal})
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+
+	slice := reflect.MakeSlice(fieldValue.Type(), len(vals), len(vals))
+	for i, val := range vals {
+		if err := setVal(slice.Index(i), val, b.layout); err != nil {
+			return b.error(val, err)
+		}
+	}
+	fieldValue.Set(slice)
+	return nil
+}
+
+func (b *valueBinder) bindMap(param reflect.Value, vals map[string]string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+
+	fieldValue := param.Field(b.field)
+	m := reflect.MakeMapWithSize(fieldValue.Type(), len(vals))
+	for k, val := range vals {
+		key := reflect.New(fieldValue.Type().Key()).Elem()
+		if err := setVal(key, k, b.layout); err != nil {
+			return b.error(k, err)
+		}
+		elem := reflect.New(fieldValue.Type().Elem()).Elem()
+		if err := setVal(elem, val, b.layout); err != nil {
+			return b.error(val, err)
+		}
+		m.SetMapIndex(key, elem)
+	}
+	fieldValue.Set(m)
+	return nil
+}
+
+func (b *valueBinder) split(vals []string) []string {
+	var res []string
+	for _, val := range vals {
+		if b.sep == "" {
+			if val != "" {
+				res = append(res, val)
+			}
+			continue
+		}
+		for _, v := range strings.Split(val, b.sep) {
+			if v != "" {
+				res = append(res, v)
+			}
+		}
+	}
+	return res
+}
+
+func (b *valueBinder) error(val string, err error) error {
+	reason := ReasonParse
+	if val == "" {
+		reason = ReasonRequired
+	}
+	return &BindError{
+		Source: b.source,
+		Field:  b.name,
+		Value:  val,
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+type pathBinder struct {
+	valueBinder
+}
+
+func (b *pathBinder) Bind(c *gin.Context, param reflect.Value) error {
+	return b.bind(param, []string{c.Param(b.name)})
 }
 
 type queryBinder struct {
-	field      int
-	query      string
-	defaultVal string
+	valueBinder
 }
 
 func (b *queryBinder) Bind(c *gin.Context, param reflect.Value) error {
-	val := c.Query(b.query)
-	if val == "" {
-		val = b.defaultVal
+	if b.kind == reflect.Map {
+		return b.bindMap(param, c.QueryMap(b.name))
 	}
-	err := setVal(param.Field(b.field), val)
-	return err
+	return b.bind(param, c.QueryArray(b.name))
 }
 
 type formBinder struct {
-	field      int
-	form       string
-	defaultVal string
+	valueBinder
 }
 
 func (b *formBinder) Bind(c *gin.Context, param reflect.Value) error {
-	val := c.PostForm(b.form)
-	if val == "" {
-		val = b.defaultVal
+	if b.kind == reflect.Map {
+		return b.bindMap(param, c.PostFormMap(b.name))
 	}
-	err := setVal(param.Field(b.field), val)
-	return err
+	return b.bind(param, c.PostFormArray(b.name))
+}
+
+type headerBinder struct {
+	valueBinder
+}
+
+func (b *headerBinder) Bind(c *gin.Context, param reflect.Value) error {
+	return b.bind(param, c.Request.Header.Values(b.name))
+}
+
+type cookieBinder struct {
+	valueBinder
 }
 
-type requestJSONBinder struct {
+func (b *cookieBinder) Bind(c *gin.Context, param reflect.Value) error {
+	val, _ := c.Cookie(b.name)
+	return b.bind(param, []string{val})
+}
+
+// bodyBinder decodes the request body with the codec of the request tag, form binds
+// urlencoded or multipart bodies by form tags and auto picks one by Content-Type.
+type bodyBinder struct {
+	it    *Interceptor
 	field int
 	body  reflect.Type
+	name  string
+	codec Codec
 }
 
-func (b *requestJSONBinder) Bind(c *gin.Context, param reflect.Value) error {
-	body := reflect.New(b.body).Interface()
-	err := c.ShouldBindJSON(body)
-	if err != nil {
-		return ErrInvalidParams
+func (it *Interceptor) newBodyBinder(i int, field reflect.StructField, name string) (*bodyBinder, error) {
+	b := &bodyBinder{it: it, field: i, body: field.Type, name: name}
+	if name == "form" || name == "auto" {
+		return b, nil
 	}
-	if validator, ok := body.(Validator); ok {
-		err = validator.Validate()
-		if err != nil {
-			return ErrInvalidParams
-		}
+	cd, ok := it.codecByName(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported request type %q", name)
 	}
-	param.Field(b.field).Set(reflect.ValueOf(body).Elem())
-	return nil
-}
-
-type fileBinder struct {
-	field int
-	file  string
+	b.codec = cd
+	return b, nil
 }
 
-func (f *fileBinder) Bind(c *gin.Context, param reflect.Value) error {
-	file, err := c.FormFile(f.file)
+func (b *bodyBinder) Bind(c *gin.Context, param reflect.Value) error {
+	bodyPtr := reflect.New(b.body)
+	body := bodyPtr.Interface()
+	if b.body.Kind() == reflect.Ptr {
+		// protobuf messages are bound as pointers
+		bodyPtr.Elem().Set(reflect.New(b.body.Elem()))
+		body = bodyPtr.Elem().Interface()
+	}
+	var err error
+	switch cd := b.codec; {
+	case b.name == "form":
+		err = bindForm(c, body)
+	case cd != nil:
+		err = decodeBody(c, cd, body)
+	default:
+		contentType := c.ContentType()
+		if contentType == "" {
+			contentType = JSONCodec.ContentType()
+		}
+		if contentType == gin.MIMEPOSTForm || contentType == gin.MIMEMultipartPOSTForm {
+			err = bindForm(c, body)
+		} else if cd, ok := b.it.codecFor(contentType); ok {
+			err = decodeBody(c, cd, body)
+		} else {
+			return NewHTTPError(http.StatusUnsupportedMediaType, http.StatusUnsupportedMediaType,
+				"unsupported content type "+contentType)
+		}
+	}
 	if err != nil {
-		return ErrInvalidParams
+		bindErr := &BindError{Source: SourceBody, Reason: ReasonParse, Err: err}
+		var typeErr *json.UnmarshalTypeError
+		// typeErr.Value is the JSON kind such as "number", the raw value is not known
+		if errors.As(err, &typeErr) {
+			bindErr.Field = typeErr.Field
+		}
+		return bindErr
 	}
-
-	param.Field(f.field).Set(reflect.ValueOf(file))
+	param.Field(b.field).Set(bodyPtr.Elem())
 	return nil
 }
 
-type multipleFileBinder struct {
-	field int
-	files string
-}
-
-func (m *multipleFileBinder) Bind(c *gin.Context, param reflect.Value) error {
-	form, err := c.MultipartForm()
+func decodeBody(c *gin.Context, cd Codec, body any) error {
+	data, err := c.GetRawData()
 	if err != nil {
-		return ErrInvalidParams
+		return err
+	}
+	if err = cd.Unmarshal(data, body); err != nil {
+		return err
 	}
+	return binding.Validator.ValidateStruct(body)
+}
 
-	param.Field(m.field).Set(reflect.ValueOf(form.File[m.files]))
-	return nil
+func bindForm(c *gin.Context, body any) error {
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		return c.ShouldBindWith(body, binding.FormMultipart)
+	}
+	return c.ShouldBindWith(body, binding.FormPost)
 }
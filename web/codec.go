@@ -0,0 +1,223 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codec encodes responses and decodes request bodies of a content type. Request bodies are
+// decoded with any known codec, responses are encoded with JSON or a codec enabled with
+// WithCodecs when the Accept header asks for it.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	XMLCodec      Codec = xmlCodec{}
+	MsgPackCodec  Codec = msgPackCodec{}
+	ProtobufCodec Codec = protobufCodec{}
+
+	defaultCodecs = []Codec{JSONCodec, XMLCodec, MsgPackCodec, ProtobufCodec}
+)
+
+// requestCodecs maps the names of the request tag to codecs, form and auto are handled by bodyBinder.
+var requestCodecs = map[string]Codec{
+	"json":     JSONCodec,
+	"xml":      XMLCodec,
+	"msgpack":  MsgPackCodec,
+	"protobuf": ProtobufCodec,
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xmlCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v any) error {
+	return xml.Unmarshal(data, v)
+}
+
+var msgPackHandle = &codec.MsgpackHandle{}
+
+type msgPackCodec struct{}
+
+func (msgPackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgPackCodec) Marshal(v any) ([]byte, error) {
+	var b []byte
+	err := codec.NewEncoderBytes(&b, msgPackHandle).Encode(v)
+	return b, err
+}
+
+func (msgPackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, msgPackHandle).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+func (it *Interceptor) codecs() []Codec {
+	if len(it.extraCodecs) == 0 {
+		return defaultCodecs
+	}
+	return append(append([]Codec{}, it.extraCodecs...), defaultCodecs...)
+}
+
+// codecFor returns the codec of a request content type
+func (it *Interceptor) codecFor(contentType string) (Codec, bool) {
+	mt := mediaType(contentType)
+	for _, cd := range it.codecs() {
+		if mediaType(cd.ContentType()) == mt {
+			return cd, true
+		}
+	}
+	return nil, false
+}
+
+// codecByName returns the codec of a request tag, the codecs of WithCodecs replace the
+// default ones of the same content type and are also found by their subtype, e.g. yaml
+// for application/x-yaml.
+func (it *Interceptor) codecByName(name string) (Codec, bool) {
+	if cd, ok := requestCodecs[name]; ok {
+		return it.codecFor(cd.ContentType())
+	}
+	for _, cd := range it.extraCodecs {
+		_, subtype, _ := strings.Cut(mediaType(cd.ContentType()), "/")
+		if strings.TrimPrefix(subtype, "x-") == name {
+			return cd, true
+		}
+	}
+	return nil, false
+}
+
+// negotiate returns the codec preferred by the Accept header among JSON and the codecs
+// enabled with WithCodecs, so browsers sending application/xml in Accept still get JSON.
+func (it *Interceptor) negotiate(c *gin.Context) Codec {
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return it.jsonCodec()
+	}
+
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mt, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	codecs := append([]Codec{it.jsonCodec()}, it.extraCodecs...)
+	for _, r := range ranges {
+		if r.mediaType == "*/*" || r.mediaType == "application/*" {
+			break
+		}
+		for _, cd := range codecs {
+			if mediaType(cd.ContentType()) == r.mediaType {
+				return cd
+			}
+		}
+	}
+	return it.jsonCodec()
+}
+
+func (it *Interceptor) jsonCodec() Codec {
+	cd, _ := it.codecFor(JSONCodec.ContentType())
+	return cd
+}
+
+// render writes data with the negotiated codec, falling back to JSON when the codec cannot
+// encode it, e.g. protobuf for errors.
+func (it *Interceptor) render(c *gin.Context, status int, data any) {
+	if len(it.extraCodecs) > 0 {
+		// the body depends on Accept once another codec than JSON can be negotiated
+		c.Writer.Header().Add("Vary", "Accept")
+	}
+	cd := it.negotiate(c)
+	b, err := cd.Marshal(data)
+	if jsonCodec := it.jsonCodec(); err != nil && mediaType(cd.ContentType()) != mediaType(jsonCodec.ContentType()) {
+		cd = jsonCodec
+		b, err = cd.Marshal(data)
+	}
+	if err != nil {
+		_ = c.Error(err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(status, cd.ContentType(), b)
+}
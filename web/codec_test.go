@@ -0,0 +1,176 @@
+package web
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testOrder struct {
+	XMLName xml.Name `json:"-" xml:"order" codec:"-"`
+	ID      int      `json:"id" xml:"id" form:"id" codec:"id"`
+	Item    string   `json:"item" xml:"item" form:"item" codec:"item"`
+}
+
+func Test_BodyCodecs(t *testing.T) {
+	echo := func(p struct {
+		Order testOrder `request:"auto"`
+	}) testOrder {
+		return p.Order
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<order><id>1</id><item>a</item></order>`))
+	req.Header.Set("Content-Type", "application/xml")
+	w, err := serve(http.MethodPost, "/", echo, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, testOrder{ID: 1, Item: "a"}, decode[testOrder](t, w))
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`id=2&item=b`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html, application/xml;q=0.9, */*;q=0.1")
+	w, err = serve(http.MethodPost, "/", echo, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "", w.Header().Get("Vary"))
+	assert.Equal(t, testOrder{ID: 2, Item: "b"}, decode[testOrder](t, w))
+
+	xmlInterceptor := NewInterceptor(WithCodecs(XMLCodec))
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`id=2&item=b`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/html, application/xml;q=0.9, */*;q=0.1")
+	w, err = serveWith(xmlInterceptor, http.MethodPost, "/", echo, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `<order><id>2</id><item>b</item></order>`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Accept", "application/xml")
+	w, _ = serveWith(xmlInterceptor, http.MethodPost, "/", echo, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	assert.Equal(t, `<error><code>415</code><message>unsupported content type text/csv</message></error>`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<order><id>x</id></order>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Accept", "application/xml")
+	w, _ = serveWith(xmlInterceptor, http.MethodPost, "/", echo, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `<error><code>400</code><message>invalid params</message>`+
+		`<details><source>body</source><field></field><reason>parse</reason></details></error>`, w.Body.String())
+
+	b, err := MsgPackCodec.Marshal(testOrder{ID: 3, Item: "c"})
+	assert.Nil(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/msgpack")
+	w, err = serveWith(NewInterceptor(WithCodecs(MsgPackCodec)), http.MethodPost, "/", echo, req)
+	assert.Nil(t, err)
+	var order testOrder
+	assert.Nil(t, MsgPackCodec.Unmarshal(w.Body.Bytes(), &order))
+	assert.Equal(t, testOrder{ID: 3, Item: "c"}, order)
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+	req.Header.Set("Content-Type", "text/csv")
+	w, _ = serve(http.MethodPost, "/", echo, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func Test_ProtobufCodec(t *testing.T) {
+	f := func(p struct {
+		Name *wrapperspb.StringValue `request:"protobuf"`
+	}) (*wrapperspb.StringValue, error) {
+		if p.Name.GetValue() == "" {
+			return nil, NewHTTPError(http.StatusBadRequest, 1, "empty name")
+		}
+		return wrapperspb.String("hello " + p.Name.GetValue()), nil
+	}
+
+	b, err := proto.Marshal(wrapperspb.String("web"))
+	assert.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	it := NewInterceptor(WithCodecs(ProtobufCodec))
+	req.Header.Set("Accept", "application/x-protobuf")
+	w, err := serveWith(it, http.MethodPost, "/", f, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+	resp := &wrapperspb.StringValue{}
+	assert.Nil(t, proto.Unmarshal(w.Body.Bytes(), resp))
+	assert.Equal(t, "hello web", resp.GetValue())
+
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(nil))
+	req.Header.Set("Accept", "application/x-protobuf")
+	w, _ = serveWith(it, http.MethodPost, "/", f, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "empty name", decode[HTTPError](t, w).Message)
+}
+
+type testYAMLCodec struct {
+	calls *int
+}
+
+func (testYAMLCodec) ContentType() string {
+	return "application/x-yaml"
+}
+
+func (cd testYAMLCodec) Marshal(v any) ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func (cd testYAMLCodec) Unmarshal(data []byte, v any) error {
+	*cd.calls++
+	if !strings.HasPrefix(string(data), "item: ") {
+		return errors.New("invalid yaml")
+	}
+	v.(*testOrder).Item = strings.TrimPrefix(string(data), "item: ")
+	return nil
+}
+
+type testStrictJSONCodec struct {
+	Codec
+	calls *int
+}
+
+func (cd testStrictJSONCodec) Unmarshal(data []byte, v any) error {
+	*cd.calls++
+	return cd.Codec.Unmarshal(data, v)
+}
+
+func Test_RequestTagCodecs(t *testing.T) {
+	echo := func(p struct {
+		Order testOrder `request:"yaml"`
+	}) testOrder {
+		return p.Order
+	}
+	assert.Panics(t, func() {
+		NewInterceptor().Intercept(echo)
+	})
+
+	calls := 0
+	it := NewInterceptor(WithCodecs(testYAMLCodec{calls: &calls}))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("item: a"))
+	w, err := serveWith(it, http.MethodPost, "/", echo, req)
+	assert.Nil(t, err)
+	assert.Equal(t, testOrder{Item: "a"}, decode[testOrder](t, w))
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	it = NewInterceptor(WithCodecs(testStrictJSONCodec{Codec: JSONCodec, calls: &calls}))
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":1}`))
+	w, err = serveWith(it, http.MethodPost, "/", func(p struct {
+		Order testOrder `request:"json"`
+	}) testOrder {
+		return p.Order
+	}, req)
+	assert.Nil(t, err)
+	assert.Equal(t, testOrder{ID: 1}, decode[testOrder](t, w))
+	assert.Equal(t, 1, calls)
+}
@@ -1,7 +1,25 @@
 package web
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+)
 
 type ServerCustomEngineConfig interface {
 	CustomEngine(engine *gin.Engine) error
 }
+
+type ServerInterceptorConfig interface {
+	InterceptorOptions() []Options
+}
+
+// ServerStartHook is called once the listener is bound and before requests are served, an
+// error aborts Run and stops the hooks already started that also implement ServerStopHook.
+type ServerStartHook interface {
+	OnStart(ctx context.Context) error
+}
+
+// ServerStopHook is called after in-flight requests are drained on shutdown.
+type ServerStopHook interface {
+	OnStop(ctx context.Context) error
+}
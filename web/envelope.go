@@ -0,0 +1,34 @@
+package web
+
+import (
+	"encoding/xml"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/trace"
+)
+
+// ResponseWrapper builds the body written for JSON responses, err is nil for successful calls.
+type ResponseWrapper func(c *gin.Context, data any, err *HTTPError) any
+
+type Envelope struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	Code    int      `json:"code" xml:"code"`
+	Msg     string   `json:"msg" xml:"msg"`
+	Data    any      `json:"data" xml:"data"`
+	TraceID string   `json:"trace_id,omitempty" xml:"trace_id,omitempty"`
+}
+
+// WrapEnvelope is a ResponseWrapper writing every response as an Envelope.
+func WrapEnvelope(c *gin.Context, data any, err *HTTPError) any {
+	envelope := Envelope{
+		Code:    0,
+		Msg:     "ok",
+		Data:    data,
+		TraceID: trace.GetTraceID(c),
+	}
+	if err != nil {
+		envelope.Code = err.Code
+		envelope.Msg = err.Message
+		envelope.Data = err.Details
+	}
+	return envelope
+}
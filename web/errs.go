@@ -1,7 +1,183 @@
 package web
 
-import "errors"
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/db"
+	"net/http"
+	"strings"
+)
 
 var (
 	ErrInvalidParams = errors.New("invalid params")
 )
+
+const (
+	SourcePath   = "path"
+	SourceQuery  = "query"
+	SourceForm   = "form"
+	SourceHeader = "header"
+	SourceCookie = "cookie"
+	SourceBody   = "body"
+	SourceFile   = "file"
+)
+
+const (
+	ReasonParse    = "parse"
+	ReasonRequired = "required"
+	ReasonValidate = "validate"
+)
+
+// BindError describes why a single field of a handler param could not be bound.
+// It matches ErrInvalidParams with errors.Is.
+type BindError struct {
+	Source string `json:"source" xml:"source"`
+	Field  string `json:"field" xml:"field"`
+	Value  string `json:"value,omitempty" xml:"value,omitempty"`
+	Reason string `json:"reason" xml:"reason"`
+	Tag    string `json:"tag,omitempty" xml:"tag,omitempty"`
+	Err    error  `json:"-" xml:"-"`
+}
+
+func (e *BindError) Error() string {
+	sb := &strings.Builder{}
+	sb.WriteString(e.Source)
+	if e.Field != "" {
+		sb.WriteString(" " + e.Field)
+	}
+	if e.Value != "" {
+		sb.WriteString(fmt.Sprintf(" %q", e.Value))
+	}
+	sb.WriteString(": " + e.Reason)
+	if e.Tag != "" {
+		sb.WriteString(" " + e.Tag)
+	}
+	if e.Err != nil {
+		sb.WriteString(": " + e.Err.Error())
+	}
+	return sb.String()
+}
+
+func (e *BindError) Is(target error) bool {
+	return target == ErrInvalidParams
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors collects every field that failed binding or validation.
+type BindErrors []*BindError
+
+func (e BindErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return ErrInvalidParams.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e BindErrors) Is(target error) bool {
+	return target == ErrInvalidParams
+}
+
+// HTTPError is an error written to the client with its own status code.
+type HTTPError struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Status  int      `json:"-" xml:"-"`
+	Code    int      `json:"code" xml:"code"`
+	Message string   `json:"message" xml:"message"`
+	Details any      `json:"details,omitempty" xml:"details,omitempty"`
+	Err     error    `json:"-" xml:"-"`
+}
+
+func NewHTTPError(status int, code int, message string) *HTTPError {
+	return &HTTPError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HTTPError) WithDetails(details any) *HTTPError {
+	ne := *e
+	ne.Details = details
+	return &ne
+}
+
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	ne := *e
+	ne.Err = err
+	return &ne
+}
+
+// Problem is the RFC 7807 representation of an HTTPError.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     int    `json:"code,omitempty"`
+	Errors   any    `json:"errors,omitempty"`
+}
+
+type ErrorMapper func(err error) *HTTPError
+
+type ErrorHandler func(c *gin.Context, err error)
+
+// MapError is the default ErrorMapper, HTTPError is kept as is, ErrInvalidParams becomes 400,
+// record not found becomes 404, ErrRequestTimeout 503 and anything else 500.
+func MapError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, http.StatusRequestEntityTooLarge, "request body too large").Wrap(err)
+	}
+	if errors.Is(err, ErrRequestTimeout) {
+		return NewHTTPError(http.StatusServiceUnavailable, http.StatusServiceUnavailable, ErrRequestTimeout.Error()).Wrap(err)
+	}
+	if errors.Is(err, ErrInvalidParams) {
+		httpErr = NewHTTPError(http.StatusBadRequest, http.StatusBadRequest, ErrInvalidParams.Error()).Wrap(err)
+		var bindErrs BindErrors
+		var bindErr *BindError
+		if errors.As(err, &bindErrs) {
+			httpErr.Details = bindErrs
+		} else if errors.As(err, &bindErr) {
+			httpErr.Details = BindErrors{bindErr}
+		}
+		return httpErr
+	}
+	if db.RecordNotFound(err) {
+		return NewHTTPError(http.StatusNotFound, http.StatusNotFound, "record not found").Wrap(err)
+	}
+	return NewHTTPError(http.StatusInternalServerError, http.StatusInternalServerError, "internal server error").Wrap(err)
+}
+
+// RouteErrors lists every route the server failed to register.
+type RouteErrors []error
+
+func (e RouteErrors) Error() string {
+	sb := &strings.Builder{}
+	sb.WriteString(fmt.Sprintf("invalid routes (%d):", len(e)))
+	for _, err := range e {
+		sb.WriteString("\n  " + err.Error())
+	}
+	return sb.String()
+}
@@ -1,5 +1,150 @@
 package web
 
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File is served from Reader, or Path opened from FS or the os file system. Seekable
+// sources support byte ranges and conditional requests through http.ServeContent.
 type File struct {
-	Path string
+	Path   string
+	FS     fs.FS
+	Reader io.Reader
+	// Name is the download name, the file is sent as an attachment unless Inline is set.
+	Name        string
+	Inline      bool
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+func NewFile(path string) File {
+	return File{Path: path}
+}
+
+func NewFileFS(fsys fs.FS, path string) File {
+	return File{FS: fsys, Path: path}
+}
+
+// NewFileReader serves generated content, r should be an io.ReadSeeker to support ranges
+// and is closed after being served if it is an io.Closer.
+func NewFileReader(name string, r io.Reader) File {
+	return File{Name: name, Reader: r}
+}
+
+func (f File) Attachment(name string) File {
+	f.Name = name
+	f.Inline = false
+	return f
+}
+
+func (f File) WithContentType(contentType string) File {
+	f.ContentType = contentType
+	return f
+}
+
+func (f File) WithETag(etag string) File {
+	f.ETag = etag
+	return f
+}
+
+func (f File) WithModTime(t time.Time) File {
+	f.ModTime = t
+	return f
+}
+
+func (f File) serve(c *gin.Context, status int) error {
+	r := f.Reader
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if r == nil {
+		file, info, err := f.open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+		if f.ModTime.IsZero() {
+			f.ModTime = info.ModTime()
+		}
+	}
+
+	name := f.Name
+	if name == "" {
+		name = filepath.Base(f.Path)
+	}
+	if f.ContentType != "" {
+		c.Header("Content-Type", f.ContentType)
+	}
+	if f.Name != "" {
+		disposition := "attachment"
+		if f.Inline {
+			disposition = "inline"
+		}
+		c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": f.Name}))
+	}
+	if f.ETag != "" {
+		etag := f.ETag
+		if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+			etag = `"` + etag + `"`
+		}
+		c.Header("ETag", etag)
+	}
+
+	if rs, ok := r.(io.ReadSeeker); ok && status == http.StatusOK {
+		http.ServeContent(c.Writer, c.Request, name, f.ModTime, rs)
+		return nil
+	}
+
+	if f.ContentType == "" {
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Content-Type", contentType)
+	}
+	if !f.ModTime.IsZero() {
+		c.Header("Last-Modified", f.ModTime.UTC().Format(http.TimeFormat))
+	}
+	c.Status(status)
+	_, err := io.Copy(c.Writer, r)
+	return err
+}
+
+func (f File) open() (fs.File, fs.FileInfo, error) {
+	var file fs.File
+	var err error
+	if f.FS != nil {
+		file, err = f.FS.Open(strings.TrimPrefix(f.Path, "/"))
+	} else {
+		file, err = os.Open(f.Path)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, NewHTTPError(http.StatusNotFound, http.StatusNotFound, "file not found").Wrap(err)
+		}
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		_ = file.Close()
+		return nil, nil, NewHTTPError(http.StatusNotFound, http.StatusNotFound, "file not found").
+			Wrap(fmt.Errorf("%s is a directory", f.Path))
+	}
+	return file, info, nil
 }
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	assert.Nil(t, os.WriteFile(path, []byte("id,name\n1,a\n"), 0644))
+
+	f := func() File { return NewFile(path).Attachment("2024 report.csv").WithETag("v1") }
+	w, err := serve(http.MethodGet, "/", f, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="2024 report.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	assert.Equal(t, "id,name\n1,a\n", w.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	w, _ = serve(http.MethodGet, "/", f, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 0-1/12", w.Header().Get("Content-Range"))
+	assert.Equal(t, "id", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	w, _ = serve(http.MethodGet, "/", f, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	fsys := fstest.MapFS{"static/app.js": {Data: []byte("run()")}}
+	w, err = serve(http.MethodGet, "/", func() File { return NewFileFS(fsys, "/static/app.js") },
+		httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.Contains(t, w.Header().Get("Content-Type"), "javascript")
+	assert.Equal(t, "run()", w.Body.String())
+
+	w, err = serve(http.MethodGet, "/", func() File {
+		return NewFileReader("data.bin", io.MultiReader(strings.NewReader("abc")))
+	}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", w.Body.String())
+
+	w, err = serve(http.MethodGet, "/", func() File { return NewFileFS(fsys, "missing") },
+		httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+type typedHandler interface {
+	reqType() reflect.Type
+}
+
+// HandlerFunc is a handler whose signature is checked by the compiler, Req must be a struct
+// and is bound like the struct params of intercepted funcs.
+type HandlerFunc[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+func (f HandlerFunc[Req, Resp]) reqType() reflect.Type {
+	return reflect.TypeOf((*Req)(nil)).Elem()
+}
+
+// NoContentFunc is a HandlerFunc without response body, it responds 204 on success.
+type NoContentFunc[Req any] func(ctx context.Context, req Req) error
+
+func (f NoContentFunc[Req]) reqType() reflect.Type {
+	return reflect.TypeOf((*Req)(nil)).Elem()
+}
+
+// Handle is used as the func of a route, e.g. Get(":id", Handle(h.getUser)).
+func Handle[Req, Resp any](f func(ctx context.Context, req Req) (Resp, error)) HandlerFunc[Req, Resp] {
+	return f
+}
+
+func HandleNoContent[Req any](f func(ctx context.Context, req Req) error) NoContentFunc[Req] {
+	return f
+}
+
+// checkHandler reports the signatures the interceptor cannot handle reliably
+func checkHandler(f any) error {
+	if h, ok := f.(typedHandler); ok {
+		if rtp := h.reqType(); rtp.Kind() != reflect.Struct {
+			return fmt.Errorf("request type %s must be a struct", rtp)
+		}
+	}
+
+	ft := reflect.TypeOf(f)
+	results := 0
+	for i := 0; i < ft.NumOut(); i++ {
+		if !ft.Out(i).Implements(typeError) {
+			results++
+		}
+	}
+	if results > 1 {
+		return fmt.Errorf("handler returns %d values besides error, at most one is allowed", results)
+	}
+	return nil
+}
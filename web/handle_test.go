@@ -0,0 +1,72 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testGetUserReq struct {
+	ID int64 `path:"id"`
+}
+
+func testGetUser(ctx context.Context, req testGetUserReq) (testUser, error) {
+	return testUser{ID: req.ID, Name: "u"}, nil
+}
+
+func Test_Handle(t *testing.T) {
+	w, err := serve(http.MethodGet, "/users/:id", Handle(testGetUser), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), decode[testUser](t, w).ID)
+
+	w, err = serve(http.MethodDelete, "/users/:id", HandleNoContent(func(ctx context.Context, req testGetUserReq) error {
+		return nil
+	}), httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+type testInvalidHandler struct{}
+
+func (h *testInvalidHandler) Base() string {
+	return "invalid"
+}
+
+func (h *testInvalidHandler) Routes() []Route {
+	return Routes(
+		Get("typed", Handle(func(ctx context.Context, id int) (string, error) {
+			return "", nil
+		})),
+		Get("unexported", func(p struct {
+			id int `path:"id"`
+		}) {
+		}),
+		Any("returns", func() (string, int) {
+			return "", 0
+		}),
+		Get("default", func(p struct {
+			Page int `query:"page" default:"first"`
+		}) {
+		}),
+		Get("valid", Handle(testGetUser)),
+	)
+}
+
+func Test_HandleInvalidRoutes(t *testing.T) {
+	s := newTestServer(&testInvalidHandler{})
+	_, err := s.engine()
+
+	var routeErrs RouteErrors
+	assert.True(t, errors.As(err, &routeErrs))
+	assert.Equal(t, 4, len(routeErrs))
+	msg := err.Error()
+	assert.True(t, strings.HasPrefix(msg, "invalid routes (4):"))
+	assert.Contains(t, msg, "GET /invalid/typed: request type int must be a struct")
+	assert.Contains(t, msg, "GET /invalid/unexported: struct { id int \"path:\\\"id\\\"\" }: path field id is unexported")
+	assert.Contains(t, msg, "ANY /invalid/returns: handler returns 2 values besides error")
+	assert.Contains(t, msg, `GET /invalid/default: struct { Page int "query:\"page\" default:\"first\"" }: query field Page: invalid default "first"`)
+}
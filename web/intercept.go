@@ -2,6 +2,8 @@ package web
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sakuradon99/gokit/opt"
 	"net/http"
@@ -16,8 +18,15 @@ var (
 )
 
 type Interceptor struct {
-	tplSuffix     string
-	viewIntercept viewIntercept
+	tplSuffix       string
+	viewIntercept   viewIntercept
+	errorHandler    ErrorHandler
+	errorMapper     ErrorMapper
+	problemDetails  bool
+	responseWrapper ResponseWrapper
+	webSocket       WebSocketConfig
+	extraCodecs     []Codec
+	resolvers       map[reflect.Type]resolver
 }
 
 func NewInterceptor(options ...Options) *Interceptor {
@@ -28,28 +37,77 @@ func NewInterceptor(options ...Options) *Interceptor {
 	return i
 }
 
-func (it *Interceptor) Intercept(f any) gin.HandlerFunc {
+// Intercept wraps f into a gin handler, it panics if the parameters of f cannot be bound.
+// The options only apply to f.
+func (it *Interceptor) Intercept(f any, options ...Options) gin.HandlerFunc {
+	h, err := it.intercept(f, options...)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+func (it *Interceptor) with(options ...Options) *Interceptor {
+	if len(options) == 0 {
+		return it
+	}
+	ni := *it
+	for _, option := range options {
+		option(&ni)
+	}
+	return &ni
+}
+
+func (it *Interceptor) intercept(f any, options ...Options) (gin.HandlerFunc, error) {
+	it = it.with(options...)
+
 	ft := reflect.TypeOf(f)
 	fv := reflect.ValueOf(f)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler must be a func, got %T", f)
+	}
+	if err := checkHandler(f); err != nil {
+		return nil, err
+	}
 	ftNumIn := ft.NumIn()
 
 	var paramBuilders []paramBuilder
+	connIndex := -1
 	for i := 0; i < ftNumIn; i++ {
 		field := ft.In(i)
+		if field == typeConn {
+			// the connection is upgraded once the other params are bound
+			connIndex = i
+			paramBuilders = append(paramBuilders, nil)
+			continue
+		}
+		if r, ok := it.resolvers[field]; ok {
+			paramBuilders = append(paramBuilders, &resolverParamBuilder{resolve: r})
+			continue
+		}
 		if field.Kind() == reflect.Struct {
-			paramBuilders = append(paramBuilders, it.buildStructParamBuilder(field))
+			builder, err := it.buildStructParamBuilder(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			paramBuilders = append(paramBuilders, builder)
 			continue
 		}
 		if field == typeGinContext || field.Implements(typeContext) {
 			paramBuilders = append(paramBuilders, newContextParamBuilder())
 			continue
 		}
-		paramBuilders = append(paramBuilders, newDefaultParamBuilder(field))
+		return nil, fmt.Errorf("no resolver for param type %s", field)
 	}
 
 	return func(c *gin.Context) {
+		defer closeFiles(c)
 		incomes := make([]reflect.Value, 0, ftNumIn)
 		for _, builder := range paramBuilders {
+			if builder == nil {
+				incomes = append(incomes, reflect.Value{})
+				continue
+			}
 			param, err := builder.Build(c)
 			if err != nil {
 				it.handleError(c, err)
@@ -58,6 +116,18 @@ func (it *Interceptor) Intercept(f any) gin.HandlerFunc {
 			incomes = append(incomes, param)
 		}
 
+		var conn *Conn
+		if connIndex >= 0 {
+			var err error
+			conn, err = it.upgrade(c)
+			if err != nil {
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+			incomes[connIndex] = reflect.ValueOf(conn)
+		}
+
 		outcomes := fv.Call(incomes)
 
 		resp := opt.Empty[reflect.Value]()
@@ -72,82 +142,146 @@ func (it *Interceptor) Intercept(f any) gin.HandlerFunc {
 				resp = opt.Of(outcome)
 			}
 		}
+		if conn != nil {
+			it.closeWebSocket(c, conn, err)
+			return
+		}
 		if err != nil {
 			it.handleError(c, err)
 			return
 		}
 
 		it.handleResponse(c, resp)
-	}
+	}, nil
 }
 
-func (it *Interceptor) buildStructParamBuilder(rtp reflect.Type) *structParamBuilder {
+func (it *Interceptor) buildStructParamBuilder(rtp reflect.Type) (*structParamBuilder, error) {
 	var binders []binder
 	for i := 0; i < rtp.NumField(); i++ {
-		field := rtp.Field(i)
-		tag := field.Tag
-		defaultVal := tag.Get("default")
-		if path := tag.Get("path"); path != "" {
-			binders = append(binders, &pathBinder{
-				field:      i,
-				path:       path,
-				defaultVal: defaultVal,
-			})
-		} else if query := tag.Get("query"); query != "" {
-			binders = append(binders, &queryBinder{
-				field:      i,
-				query:      query,
-				defaultVal: defaultVal,
-			})
-		} else if form := tag.Get("form"); form != "" {
-			binders = append(binders, &formBinder{
-				field:      i,
-				form:       form,
-				defaultVal: defaultVal,
-			})
-		} else if request := tag.Get("request"); request != "" {
-			if request == "json" {
-				binders = append(binders, &requestJSONBinder{
-					field: i,
-					body:  field.Type,
-				})
-			}
-		} else if file := tag.Get("file"); file != "" {
-			binders = append(binders, &fileBinder{
-				field: i,
-				file:  file,
-			})
-		} else if files := tag.Get("files"); files != "" {
-			binders = append(binders, &multipleFileBinder{
-				field: i,
-				files: files,
-			})
+		b, err := it.buildBinder(i, rtp.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			binders = append(binders, b)
 		}
 	}
 
-	return newStructParamBuilder(rtp, binders)
+	return newStructParamBuilder(rtp, binders), nil
+}
+
+func (it *Interceptor) buildBinder(i int, field reflect.StructField) (binder, error) {
+	source, name := fieldSource(field)
+	if source != "" && !field.IsExported() {
+		return nil, fmt.Errorf("%s field %s is unexported", source, field.Name)
+	}
+	switch source {
+	case SourcePath:
+		vb, err := newValueBinder(i, field, source, name)
+		return &pathBinder{vb}, err
+	case SourceQuery:
+		vb, err := newValueBinder(i, field, source, name)
+		return &queryBinder{vb}, err
+	case SourceForm:
+		vb, err := newValueBinder(i, field, source, name)
+		return &formBinder{vb}, err
+	case SourceHeader:
+		vb, err := newValueBinder(i, field, source, name)
+		return &headerBinder{vb}, err
+	case SourceCookie:
+		vb, err := newValueBinder(i, field, source, name)
+		return &cookieBinder{vb}, err
+	case SourceBody:
+		return it.newBodyBinder(i, field, name)
+	case SourceFile:
+		return newFileBinder(i, field, name)
+	}
+	return nil, nil
 }
 
 func (it *Interceptor) handleError(c *gin.Context, err error) {
+	// the handler gave up on the deadline of RequestTimeout, not on one of its own calls
+	if errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrRequestTimeout) && deadlineExceeded(c) {
+		err = fmt.Errorf("%w: %v", ErrRequestTimeout, err)
+	}
 	_ = c.Error(err)
 	c.Abort()
+	if it.errorHandler != nil {
+		it.errorHandler(c, err)
+		return
+	}
+	if c.Writer.Written() {
+		return
+	}
+	it.writeError(c, it.mapError(err))
+}
+
+func (it *Interceptor) mapError(err error) *HTTPError {
+	if it.errorMapper != nil {
+		if httpErr := it.errorMapper(err); httpErr != nil {
+			return httpErr
+		}
+	}
+	return MapError(err)
+}
+
+func (it *Interceptor) writeError(c *gin.Context, httpErr *HTTPError) {
+	if it.problemDetails {
+		c.Header("Content-Type", "application/problem+json")
+		c.JSON(httpErr.Status, Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(httpErr.Status),
+			Status:   httpErr.Status,
+			Detail:   httpErr.Message,
+			Instance: c.Request.URL.Path,
+			Code:     httpErr.Code,
+			Errors:   httpErr.Details,
+		})
+		return
+	}
+	if it.responseWrapper != nil {
+		it.render(c, httpErr.Status, it.responseWrapper(c, nil, httpErr))
+		return
+	}
+	it.render(c, httpErr.Status, httpErr)
 }
 
 func (it *Interceptor) handleResponse(c *gin.Context, resp opt.Optional[reflect.Value]) {
 	if !resp.Exists() {
-		c.Status(http.StatusNoContent)
+		it.writeNoContent(c, http.StatusOK)
+		return
+	}
+	it.writeResponse(c, http.StatusOK, resp.Get())
+}
+
+func (it *Interceptor) writeResponse(c *gin.Context, status int, resp reflect.Value) {
+	if !resp.IsValid() {
+		it.writeNoContent(c, status)
+		return
+	}
+	if (resp.Kind() == reflect.Ptr || resp.Kind() == reflect.Interface || resp.Kind() == reflect.Chan) && resp.IsNil() {
+		it.writeNoContent(c, status)
+		return
+	}
+	if resp.Kind() == reflect.Slice && resp.IsNil() {
+		it.writeData(c, status, make([]any, 0))
 		return
 	}
-	if (resp.Get().Kind() == reflect.Ptr || resp.Get().Kind() == reflect.Interface) && resp.Get().IsNil() {
-		c.Status(http.StatusNoContent)
+	if r, ok := resp.Interface().(responder); ok {
+		status, body := r.apply(c)
+		it.writeResponse(c, status, reflect.ValueOf(body))
 		return
 	}
-	if resp.Get().Kind() == reflect.Slice && resp.Get().IsNil() {
-		c.JSON(http.StatusOK, make([]any, 0))
+	if s, ok := resp.Interface().(streamer); ok {
+		s.stream(it, c, status)
+		return
+	}
+	if resp.Kind() == reflect.Chan && resp.Type().ChanDir()&reflect.RecvDir != 0 {
+		chanStream{ch: resp}.stream(it, c, status)
 		return
 	}
 	// TODO refactor the view handler
-	if v, ok := resp.Get().Interface().(View); ok {
+	if v, ok := resp.Interface().(View); ok {
 		if it.tplSuffix != "" && !strings.HasSuffix(v.Tpl, it.tplSuffix) {
 			v.Tpl = v.Tpl + it.tplSuffix
 		}
@@ -158,13 +292,34 @@ func (it *Interceptor) handleResponse(c *gin.Context, resp opt.Optional[reflect.
 			v = it.viewIntercept(c, v)
 		}
 
-		c.HTML(http.StatusOK, v.Tpl, v.Data)
+		c.HTML(status, v.Tpl, v.Data)
 		return
 	}
-	if v, ok := resp.Get().Interface().(File); ok {
-		c.File(v.Path)
+	if v, ok := resp.Interface().(File); ok {
+		if err := v.serve(c, status); err != nil {
+			it.handleError(c, err)
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, resp.Get().Interface())
+	it.writeData(c, status, resp.Interface())
+}
+
+// writeNoContent writes 204 unless the handler asked for another status
+func (it *Interceptor) writeNoContent(c *gin.Context, status int) {
+	if status == http.StatusNoContent || it.responseWrapper == nil {
+		if status == http.StatusOK {
+			status = http.StatusNoContent
+		}
+		c.Status(status)
+		return
+	}
+	it.render(c, status, it.responseWrapper(c, nil, nil))
+}
+
+func (it *Interceptor) writeData(c *gin.Context, status int, data any) {
+	if it.responseWrapper != nil {
+		data = it.responseWrapper(c, data, nil)
+	}
+	it.render(c, status, data)
 }
@@ -0,0 +1,386 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func serve(method, path string, f any, req *http.Request) (*httptest.ResponseRecorder, error) {
+	return serveWith(NewInterceptor(), method, path, f, req)
+}
+
+func serveWith(it *Interceptor, method, path string, f any, req *http.Request) (*httptest.ResponseRecorder, error) {
+	var err error
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		if last := c.Errors.Last(); last != nil {
+			err = last.Err
+		}
+	})
+	engine.Handle(method, path, it.Intercept(f))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w, err
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	var v T
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &v))
+	return v
+}
+
+func Test_HeaderCookieBinder(t *testing.T) {
+	type params struct {
+		Tenant  string `header:"X-Tenant-ID"`
+		Version int    `header:"X-Version" default:"1"`
+		Session string `cookie:"session"`
+		Theme   string `cookie:"theme" default:"light"`
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "t1")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	w, err := serve(http.MethodGet, "/", func(p params) params { return p }, req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, params{
+		Tenant:  "t1",
+		Version: 1,
+		Session: "s1",
+		Theme:   "light",
+	}, decode[params](t, w))
+}
+
+func Test_QuerySliceMapBinder(t *testing.T) {
+	type params struct {
+		IDs    []int64           `query:"id"`
+		Tags   []string          `query:"tags" sep:","`
+		Sort   []string          `query:"sort" sep:"," default:"id,name"`
+		Filter map[string]string `query:"filter"`
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?id=1&id=2&id=3&tags=a,b&tags=c&filter[name]=x&filter[age]=1", nil)
+	w, err := serve(http.MethodGet, "/", func(p params) params { return p }, req)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, params{
+		IDs:    []int64{1, 2, 3},
+		Tags:   []string{"a", "b", "c"},
+		Sort:   []string{"id", "name"},
+		Filter: map[string]string{"name": "x", "age": "1"},
+	}, decode[params](t, w))
+
+	req = httptest.NewRequest(http.MethodGet, "/?id=1&id=x", nil)
+	_, err = serve(http.MethodGet, "/", func(p params) params { return p }, req)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+	assert.Contains(t, err.Error(), `query id "x"`)
+}
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func Test_ScalarValues(t *testing.T) {
+	type kind string
+	type params struct {
+		Verbose bool          `query:"verbose"`
+		Ratio   float64       `query:"ratio"`
+		Kind    kind          `query:"kind"`
+		Day     time.Time     `query:"day" layout:"2006-01-02"`
+		At      *time.Time    `query:"at"`
+		Wait    time.Duration `query:"wait"`
+		Level   testLevel     `query:"level"`
+		Limit   *int          `query:"limit"`
+	}
+
+	var got params
+	req := httptest.NewRequest(http.MethodGet, "/?verbose=true&ratio=0.5&kind=a&day=2023-01-02&wait=1m&level=high", nil)
+	_, err := serve(http.MethodGet, "/", func(p params) { got = p }, req)
+	assert.Nil(t, err)
+	assert.Equal(t, params{
+		Verbose: true,
+		Ratio:   0.5,
+		Kind:    "a",
+		Day:     time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		Wait:    time.Minute,
+		Level:   2,
+	}, got)
+
+	req = httptest.NewRequest(http.MethodGet, "/?verbose=true&ratio=0.5&kind=a&day=2023-01-02&wait=1m&level=none", nil)
+	_, err = serve(http.MethodGet, "/", func(p params) { got = p }, req)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	req = httptest.NewRequest(http.MethodGet, "/?kind=a&level=low", nil)
+	_, err = serve(http.MethodGet, "/", func(p params) { got = p }, req)
+	assert.Nil(t, err)
+	assert.Equal(t, params{Kind: "a", Level: 1}, got)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = serve(http.MethodGet, "/", func(p params) { got = p }, req)
+	assert.Nil(t, err)
+	assert.Equal(t, params{}, got)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = serve(http.MethodGet, "/", func(p struct {
+		Level testLevel `query:"level" validate:"required"`
+	}) {
+	}, req)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	assert.Panics(t, func() {
+		NewInterceptor().Intercept(func(p struct {
+			C chan int `query:"c"`
+		}) {
+		})
+	})
+}
+
+type testBody struct {
+	Name string `json:"name" validate:"required"`
+	Min  int    `json:"min"`
+	Max  int    `json:"max"`
+}
+
+func (b testBody) Validate() error {
+	if b.Min > b.Max {
+		return errors.New("min greater than max")
+	}
+	return nil
+}
+
+func Test_Validate(t *testing.T) {
+	type params struct {
+		ID   int64    `path:"id" validate:"min=1"`
+		Page int      `query:"page" default:"1" validate:"min=1,max=100"`
+		Body testBody `request:"json"`
+	}
+	f := func(p params) {}
+
+	cases := []struct {
+		url   string
+		body  string
+		valid bool
+	}{
+		{"/1", `{"name":"a","min":1,"max":2}`, true},
+		{"/0", `{"name":"a","min":1,"max":2}`, false},
+		{"/1?page=101", `{"name":"a","min":1,"max":2}`, false},
+		{"/1", `{"min":1,"max":2}`, false},
+		{"/1", `{"name":"a","min":3,"max":2}`, false},
+	}
+	for _, cs := range cases {
+		req := httptest.NewRequest(http.MethodPost, cs.url, strings.NewReader(cs.body))
+		_, err := serve(http.MethodPost, "/:id", f, req)
+		if cs.valid {
+			assert.Nil(t, err, cs.url+cs.body)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidParams, cs.url+cs.body)
+		}
+	}
+}
+
+func Test_BindErrors(t *testing.T) {
+	type params struct {
+		ID   int64    `path:"id" validate:"min=1"`
+		Page int      `query:"page"`
+		Body testBody `request:"json"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/0?page=x", strings.NewReader(`{"min":1}`))
+	_, err := serve(http.MethodPost, "/:id", func(p params) {}, req)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+	var bindErrs BindErrors
+	assert.True(t, errors.As(err, &bindErrs))
+	assert.Equal(t, 1, len(bindErrs))
+	assert.Equal(t, SourceQuery, bindErrs[0].Source)
+	assert.Equal(t, "page", bindErrs[0].Field)
+	assert.Equal(t, "x", bindErrs[0].Value)
+	assert.Equal(t, ReasonParse, bindErrs[0].Reason)
+
+	req = httptest.NewRequest(http.MethodPost, "/0", strings.NewReader(`{"min":1}`))
+	_, err = serve(http.MethodPost, "/:id", func(p params) {}, req)
+	assert.True(t, errors.As(err, &bindErrs))
+	assert.Equal(t, BindErrors{
+		{Source: SourceQuery, Field: "page", Reason: ReasonRequired},
+	}, clearErrs(bindErrs))
+
+	req = httptest.NewRequest(http.MethodPost, "/0?page=1", strings.NewReader(`{"min":1}`))
+	_, err = serve(http.MethodPost, "/:id", func(p params) {}, req)
+	assert.True(t, errors.As(err, &bindErrs))
+	assert.Equal(t, BindErrors{
+		{Source: SourcePath, Field: "id", Value: "0", Reason: ReasonValidate, Tag: "min=1"},
+		{Source: SourceBody, Field: "name", Reason: ReasonRequired, Tag: "required"},
+	}, clearErrs(bindErrs))
+
+	req = httptest.NewRequest(http.MethodPost, "/1?page=1", strings.NewReader(`{"name":1}`))
+	_, err = serve(http.MethodPost, "/:id", func(p params) {}, req)
+	assert.True(t, errors.As(err, &bindErrs))
+	assert.Contains(t, bindErrs[0].Err.Error(), "number")
+	assert.Equal(t, BindErrors{
+		{Source: SourceBody, Field: "name", Reason: ReasonParse},
+	}, clearErrs(bindErrs))
+}
+
+func Test_BindErrorsAnonymousParams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := serve(http.MethodGet, "/", func(p struct {
+		Name string `query:"name" validate:"required"`
+	}) {
+	}, req)
+	var bindErrs BindErrors
+	assert.True(t, errors.As(err, &bindErrs))
+	assert.Equal(t, BindErrors{
+		{Source: SourceQuery, Field: "name", Reason: ReasonRequired, Tag: "required"},
+	}, clearErrs(bindErrs))
+}
+
+func clearErrs(errs BindErrors) BindErrors {
+	for _, err := range errs {
+		err.Err = nil
+	}
+	return errs
+}
+
+func Test_ErrorMapping(t *testing.T) {
+	type params struct {
+		ID int64 `path:"id"`
+	}
+	f := func(p params) error {
+		switch p.ID {
+		case 1:
+			return gorm.ErrRecordNotFound
+		case 2:
+			return NewHTTPError(http.StatusConflict, 1001, "duplicated")
+		case 3:
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	w, _ := serve(http.MethodGet, "/:id", f, httptest.NewRequest(http.MethodGet, "/x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	body := decode[map[string]any](t, w)
+	assert.Equal(t, "invalid params", body["message"])
+	assert.Equal(t, "id", body["details"].([]any)[0].(map[string]any)["field"])
+
+	w, _ = serve(http.MethodGet, "/:id", f, httptest.NewRequest(http.MethodGet, "/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = serve(http.MethodGet, "/:id", f, httptest.NewRequest(http.MethodGet, "/2", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, map[string]any{"code": 1001.0, "message": "duplicated"}, decode[map[string]any](t, w))
+
+	w, _ = serve(http.MethodGet, "/:id", f, httptest.NewRequest(http.MethodGet, "/3", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "internal server error", decode[map[string]any](t, w)["message"])
+
+	w, _ = serveWith(NewInterceptor(WithProblemDetails()), http.MethodGet, "/:id", f, httptest.NewRequest(http.MethodGet, "/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, Problem{
+		Type:     "about:blank",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "record not found",
+		Instance: "/1",
+		Code:     http.StatusNotFound,
+	}, decode[Problem](t, w))
+
+	handled := false
+	it := NewInterceptor(WithErrorHandler(func(c *gin.Context, err error) {
+		handled = true
+		c.Status(http.StatusTeapot)
+	}))
+	w, _ = serveWith(it, http.MethodGet, "/:id", f, httptest.NewRequest(http.MethodGet, "/3", nil))
+	assert.True(t, handled)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+}
+
+func Test_ResponseWrapper(t *testing.T) {
+	type params struct {
+		ID int64 `path:"id"`
+	}
+	f := func(p params) (map[string]int64, error) {
+		if p.ID == 0 {
+			return nil, NewHTTPError(http.StatusNotFound, 1404, "not found")
+		}
+		return map[string]int64{"id": p.ID}, nil
+	}
+	serveEnvelope := func(path string, options ...Options) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.Use(func(c *gin.Context) {
+			c.Set("trace_id", "t1")
+		})
+		engine.GET("/:id", NewInterceptor(WithResponseWrapper(WrapEnvelope)).Intercept(f, options...))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serveEnvelope("/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"code":0,"msg":"ok","data":{"id":1},"trace_id":"t1"}`, w.Body.String())
+
+	w = serveEnvelope("/0")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"code":1404,"msg":"not found","data":null,"trace_id":"t1"}`, w.Body.String())
+
+	w = serveEnvelope("/1", WithRawResponse())
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+}
+
+func Test_Response(t *testing.T) {
+	type params struct {
+		ID int64 `path:"id"`
+	}
+	type item struct {
+		ID int64 `json:"id"`
+	}
+	f := func(p params) Response[*item] {
+		switch p.ID {
+		case 1:
+			return Created("/items/1", &item{ID: 1}).WithCookie(&http.Cookie{Name: "k", Value: "v"})
+		case 2:
+			return Accepted[*item](nil)
+		}
+		return Response[*item]{Body: &item{ID: p.ID}}
+	}
+
+	w, _ := serve(http.MethodPost, "/:id", f, httptest.NewRequest(http.MethodPost, "/1", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/items/1", w.Header().Get("Location"))
+	assert.Equal(t, "k=v", w.Header().Get("Set-Cookie"))
+	assert.Equal(t, `{"id":1}`, w.Body.String())
+
+	w, _ = serve(http.MethodPost, "/:id", f, httptest.NewRequest(http.MethodPost, "/2", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "", w.Body.String())
+
+	w, _ = serve(http.MethodPost, "/:id", f, httptest.NewRequest(http.MethodPost, "/3", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"id":3}`, w.Body.String())
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	keyRawBody     = "web_raw_body"
+	keyBaseContext = "web_base_context"
+)
+
+// ErrRequestTimeout is returned for requests whose handler did not respond before the
+// deadline of RequestTimeout, it is mapped to 503.
+var ErrRequestTimeout = errors.New("request timeout")
+
+// MaxBodyBytes limits the request body read by binders, reading more fails with an
+// *http.MaxBytesError which is mapped to 413. Route middlewares override the server
+// limit, n <= 0 removes it.
+func MaxBodyBytes(n int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, ok := c.Value(keyRawBody).(io.ReadCloser)
+		if !ok {
+			body = c.Request.Body
+			c.Set(keyRawBody, body)
+		}
+		if body == nil || n <= 0 {
+			c.Request.Body = body
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, body, n)
+	}
+}
+
+// RequestTimeout overrides the deadline of Interceptor.RequestTimeout for a route, d <= 0
+// removes it, e.g. for streams and WebSocket routes. It only sets the deadline, the 503 is
+// written by the server with its own interceptor options.
+func RequestTimeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		base, ok := c.Value(keyBaseContext).(context.Context)
+		if !ok {
+			base = c.Request.Context()
+			c.Set(keyBaseContext, base)
+		}
+		if d <= 0 {
+			c.Request = c.Request.WithContext(base)
+			return
+		}
+		ctx, cancel := context.WithTimeout(base, d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+var requestTimeoutName = funcName(RequestTimeout(0))
+
+// isRequestTimeout reports whether h was returned by RequestTimeout, closures of the same
+// function literal share their name.
+func isRequestTimeout(h gin.HandlerFunc) bool {
+	return funcName(h) == requestTimeoutName
+}
+
+// RequestTimeout cancels the context of the handler after d, d <= 0 sets no deadline but
+// still lets routes set one with RequestTimeout. Handlers should return the context error,
+// which is mapped to 503. Handlers ignoring the context still run to the end but what they
+// write after the deadline is dropped and 503 is written instead, unless the response was
+// started in time. The gin engine needs ContextWithFallback for *gin.Context to be
+// cancelled, Server sets it when web.request_timeout is configured or a route uses
+// RequestTimeout.
+func (it *Interceptor) RequestTimeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(keyBaseContext, c.Request.Context())
+		if d > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		w := &timeoutWriter{ResponseWriter: c.Writer, c: c}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		// nothing responded in time
+		if w.timedOut || (!c.Writer.Written() && c.Writer.Status() == http.StatusOK && deadlineExceeded(c)) {
+			it.handleError(c, ErrRequestTimeout)
+		}
+	}
+}
+
+// deadlineExceeded checks c.Request as routes may override the timeout
+func deadlineExceeded(c *gin.Context) bool {
+	return errors.Is(c.Request.Context().Err(), context.DeadlineExceeded)
+}
+
+// timeoutWriter drops the response of a handler that did not start writing it before
+// the deadline, RequestTimeout then writes ErrRequestTimeout.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	timedOut bool
+}
+
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && !w.ResponseWriter.Written() && deadlineExceeded(w.c) {
+		w.timedOut = true
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if !w.expired() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.expired() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if w.expired() {
+		return 0, ErrRequestTimeout
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return 0, ErrRequestTimeout
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	if !w.expired() {
+		w.ResponseWriter.Flush()
+	}
+}
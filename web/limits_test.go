@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLimitHandler struct{}
+
+func (h *testLimitHandler) Base() string {
+	return ""
+}
+
+func (h *testLimitHandler) Routes() []Route {
+	echo := func(p struct {
+		Body map[string]string `request:"json"`
+	}) map[string]string {
+		return p.Body
+	}
+	wait := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		}
+	}
+	downstream := func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	late := func() string {
+		time.Sleep(50 * time.Millisecond)
+		return "late"
+	}
+	return Routes(
+		Post("echo", echo),
+		Get("late", late),
+		Get("downstream", downstream),
+		Post("upload", echo).Use(MaxBodyBytes(1<<20)),
+		Get("wait", wait),
+		Get("stream", wait).Use(RequestTimeout(0)),
+	)
+}
+
+func Test_Limits(t *testing.T) {
+	s := newTestServer(&testLimitHandler{})
+	s.maxBodyBytes = 16
+	s.requestTimeoutStr = "20ms"
+	assert.Nil(t, s.Init())
+	engine, err := s.engine()
+	assert.Nil(t, err)
+	assert.True(t, engine.ContextWithFallback)
+
+	body := `{"name":"a long enough name"}`
+	for _, tt := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodPost, "/echo", http.StatusRequestEntityTooLarge},
+		{http.MethodPost, "/upload", http.StatusOK},
+		{http.MethodGet, "/wait", http.StatusServiceUnavailable},
+		{http.MethodGet, "/late", http.StatusServiceUnavailable},
+		{http.MethodGet, "/downstream", http.StatusInternalServerError},
+		{http.MethodGet, "/stream", http.StatusNoContent},
+	} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(body)))
+		assert.Equal(t, tt.status, w.Code, tt.path)
+		assert.NotContains(t, w.Body.String(), "late", tt.path)
+	}
+
+	s = newTestServer(&testPingHandler{})
+	engine, err = s.engine()
+	assert.Nil(t, err)
+	assert.False(t, engine.ContextWithFallback)
+}
+
+type testRouteTimeoutHandler struct {
+	cancelled chan struct{}
+}
+
+func (h *testRouteTimeoutHandler) Base() string {
+	return ""
+}
+
+func (h *testRouteTimeoutHandler) Routes() []Route {
+	return Routes(
+		Get("wait", func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				close(h.cancelled)
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		}).Use(RequestTimeout(20 * time.Millisecond)),
+	)
+}
+
+func Test_RouteTimeout(t *testing.T) {
+	h := &testRouteTimeoutHandler{cancelled: make(chan struct{})}
+	s := newTestServer(h)
+	s.interceptorConfigs = []ServerInterceptorConfig{testEnvelopeConfig{}}
+	engine, err := s.engine()
+	assert.Nil(t, err)
+	assert.True(t, engine.ContextWithFallback)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wait", nil))
+	select {
+	case <-h.cancelled:
+	default:
+		t.Fatal("handler context not cancelled")
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	body := decode[gin.H](t, w)
+	assert.Equal(t, float64(http.StatusServiceUnavailable), body["code"])
+	assert.Equal(t, "request timeout", body["msg"])
+}
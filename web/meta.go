@@ -0,0 +1,81 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+)
+
+const keyRoute = "web_route"
+
+// RouteMeta describes a route to middlewares and handlers, groups pass their tags,
+// roles, rate limit class and values down to nested routes.
+type RouteMeta struct {
+	Name      string
+	Tags      []string
+	Roles     []string
+	RateLimit string
+	Values    map[string]any
+}
+
+func (m RouteMeta) HasTag(tag string) bool {
+	return contains(m.Tags, tag)
+}
+
+func (m RouteMeta) HasRole(role string) bool {
+	return contains(m.Roles, role)
+}
+
+func (m RouteMeta) Value(key string) (any, bool) {
+	val, ok := m.Values[key]
+	return val, ok
+}
+
+// inherit returns m on top of the meta of its group
+func (m RouteMeta) inherit(group RouteMeta) RouteMeta {
+	m.Tags = append(append([]string{}, group.Tags...), m.Tags...)
+	m.Roles = append(append([]string{}, group.Roles...), m.Roles...)
+	if m.RateLimit == "" {
+		m.RateLimit = group.RateLimit
+	}
+	if len(group.Values) > 0 {
+		values := make(map[string]any, len(group.Values)+len(m.Values))
+		for k, v := range group.Values {
+			values[k] = v
+		}
+		for k, v := range m.Values {
+			values[k] = v
+		}
+		m.Values = values
+	}
+	return m
+}
+
+// RouteInfo is the resolved route a request is handled by.
+type RouteInfo struct {
+	Method string
+	Path   string
+	Base   string
+	Meta   RouteMeta
+}
+
+// GetRoute returns the route of the current request, ctx must be the *gin.Context
+// passed to middlewares and handlers of a Server route.
+func GetRoute(ctx context.Context) (RouteInfo, bool) {
+	info, ok := ctx.Value(keyRoute).(RouteInfo)
+	return info, ok
+}
+
+func routeInfoMiddleware(info RouteInfo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(keyRoute, info)
+	}
+}
+
+func contains(arr []string, s string) bool {
+	for _, e := range arr {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,155 @@
 package web
 
-import "github.com/gin-gonic/gin"
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"path"
+	"regexp"
+	"strings"
+)
 
+// Middleware is registered by path only, see RouteMiddleware.
 type Middleware interface {
 	Register(path string) int
 	Handle(ctx *gin.Context)
 }
 
+// RouteMiddleware is registered with the method, full path, handler base and metadata of
+// every route, a negative order skips the route and lower orders run first.
+type RouteMiddleware interface {
+	RegisterRoute(route RouteInfo) int
+	Handle(ctx *gin.Context)
+}
+
+// AdaptMiddleware turns a Middleware into a RouteMiddleware registered by the route path.
+func AdaptMiddleware(m Middleware) RouteMiddleware {
+	if rm, ok := m.(RouteMiddleware); ok {
+		return rm
+	}
+	return &pathMiddleware{m}
+}
+
+type pathMiddleware struct {
+	Middleware
+}
+
+func (m *pathMiddleware) RegisterRoute(route RouteInfo) int {
+	return m.Register(route.Path)
+}
+
+// NewRouteMiddleware registers handle with order on the routes matched by matcher.
+func NewRouteMiddleware(order int, matcher RouteMatcher, handle gin.HandlerFunc) *MatcherMiddleware {
+	return &MatcherMiddleware{
+		Order:   order,
+		Matcher: matcher,
+		Handler: handle,
+	}
+}
+
+// MatcherMiddleware is a RouteMiddleware running Handler with Order on the routes matched
+// by Matcher. It can be returned by an ioc constructor, or embedded in a type of its own
+// when several are registered, to be discovered by Server.
+type MatcherMiddleware struct {
+	Order   int
+	Matcher RouteMatcher
+	Handler gin.HandlerFunc
+}
+
+func (m *MatcherMiddleware) RegisterRoute(route RouteInfo) int {
+	if !m.Matcher.Match(route) {
+		return -1
+	}
+	return m.Order
+}
+
+func (m *MatcherMiddleware) Handle(ctx *gin.Context) {
+	m.Handler(ctx)
+}
+
+// RouteMatcher matches routes with one of Methods (any if empty) whose path matches one of
+// Include (any if empty) and none of Exclude.
+type RouteMatcher struct {
+	Methods []string
+	Include []PathPattern
+	Exclude []PathPattern
+}
+
+func (m RouteMatcher) Match(route RouteInfo) bool {
+	if len(m.Methods) > 0 && !contains(m.Methods, route.Method) {
+		return false
+	}
+	for _, pattern := range m.Exclude {
+		if pattern.Match(route.Path) {
+			return false
+		}
+	}
+	if len(m.Include) == 0 {
+		return true
+	}
+	for _, pattern := range m.Include {
+		if pattern.Match(route.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+type PathPattern interface {
+	Match(path string) bool
+}
+
+type PathPatternFunc func(path string) bool
+
+func (f PathPatternFunc) Match(path string) bool {
+	return f(path)
+}
+
+// Prefix matches p and every path below it, "/api" matches "/api/users" but not "/apis".
+func Prefix(p string) PathPattern {
+	p = strings.TrimSuffix(p, "/")
+	return PathPatternFunc(func(routePath string) bool {
+		return routePath == p || strings.HasPrefix(routePath, p+"/") || p == ""
+	})
+}
+
+// Glob matches with path.Match, a trailing "/**" matches every path below the pattern.
+func Glob(pattern string) PathPattern {
+	if strings.HasSuffix(pattern, "/**") {
+		prefix := strings.TrimSuffix(pattern, "/**")
+		n := strings.Count(prefix, "/")
+		return PathPatternFunc(func(routePath string) bool {
+			segments := strings.Split(routePath, "/")
+			if len(segments) <= n {
+				return false
+			}
+			ok, _ := path.Match(prefix, strings.Join(segments[:n+1], "/"))
+			return ok
+		})
+	}
+	return PathPatternFunc(func(routePath string) bool {
+		ok, _ := path.Match(pattern, routePath)
+		return ok
+	})
+}
+
+// Regex matches paths with the regular expression expr, it panics if expr is invalid.
+func Regex(expr string) PathPattern {
+	re := regexp.MustCompile(expr)
+	return PathPatternFunc(re.MatchString)
+}
+
 type middlewareHandlerWithOrder struct {
 	fn    gin.HandlerFunc
+	name  string
 	order int
 }
+
+func middlewareName(m RouteMiddleware) string {
+	switch m := m.(type) {
+	case *pathMiddleware:
+		return fmt.Sprintf("%T", m.Middleware)
+	case *MatcherMiddleware:
+		return funcName(m.Handler)
+	}
+	return fmt.Sprintf("%T", m)
+}
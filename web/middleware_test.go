@@ -0,0 +1,97 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_PathPatterns(t *testing.T) {
+	cases := []struct {
+		pattern PathPattern
+		path    string
+		match   bool
+	}{
+		{Prefix("/api"), "/api", true},
+		{Prefix("/api/"), "/api/users", true},
+		{Prefix("/api"), "/apis", false},
+		{Glob("/api/*/users"), "/api/v1/users", true},
+		{Glob("/api/*/users"), "/api/v1/users/:id", false},
+		{Glob("/api/*/**"), "/api/v1/users/:id", true},
+		{Glob("/api/*/**"), "/api", false},
+		{Glob("/api/**"), "/api", true},
+		{Regex(`^/api/v[0-9]+/`), "/api/v2/users", true},
+		{Regex(`^/api/v[0-9]+/`), "/api/latest/users", false},
+	}
+	for _, cs := range cases {
+		assert.Equal(t, cs.match, cs.pattern.Match(cs.path), cs.path)
+	}
+
+	matcher := RouteMatcher{
+		Methods: []string{http.MethodPost, http.MethodPut},
+		Include: []PathPattern{Prefix("/api")},
+		Exclude: []PathPattern{Glob("/api/public/**")},
+	}
+	assert.True(t, matcher.Match(RouteInfo{Method: http.MethodPost, Path: "/api/users"}))
+	assert.False(t, matcher.Match(RouteInfo{Method: http.MethodGet, Path: "/api/users"}))
+	assert.False(t, matcher.Match(RouteInfo{Method: http.MethodPost, Path: "/api/public/login"}))
+	assert.False(t, matcher.Match(RouteInfo{Method: http.MethodPost, Path: "/health"}))
+}
+
+type testPathMiddleware struct {
+	paths []string
+}
+
+func (m *testPathMiddleware) Register(path string) int {
+	m.paths = append(m.paths, path)
+	return 1
+}
+
+func (m *testPathMiddleware) Handle(c *gin.Context) {
+	c.Header("X-Path", "1")
+}
+
+// testDeleteGuard is how a MatcherMiddleware is declared as a bean type of its own
+type testDeleteGuard struct {
+	MatcherMiddleware
+}
+
+func newTestDeleteGuard() *testDeleteGuard {
+	return &testDeleteGuard{MatcherMiddleware{
+		Matcher: RouteMatcher{
+			Methods: []string{http.MethodDelete},
+			Include: []PathPattern{Glob("/api/v1/**")},
+		},
+		Handler: func(c *gin.Context) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		},
+	}}
+}
+
+func Test_RouteMiddleware(t *testing.T) {
+	legacy := &testPathMiddleware{}
+	s := newTestServer(&testGroupHandler{calls: new([]string)})
+	s.middlewares = []Middleware{legacy}
+	s.routeMiddlewares = []RouteMiddleware{
+		newTestDeleteGuard(),
+		NewRouteMiddleware(1, RouteMatcher{Exclude: []PathPattern{Prefix("/api")}}, func(c *gin.Context) {
+			c.Header("X-Public", "1")
+		}),
+	}
+	engine, err := s.engine()
+	assert.Nil(t, err)
+	assert.Contains(t, legacy.paths, "/api/v1/users")
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Path"))
+	assert.Equal(t, "", w.Header().Get("X-Public"))
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/users/1", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "", w.Header().Get("X-Path"))
+}
@@ -0,0 +1,528 @@
+package web
+
+import (
+	"encoding"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/web/openapi"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	typeView          = reflect.TypeOf(View{})
+	typeFile          = reflect.TypeOf(File{})
+	typeResponder     = reflect.TypeOf((*responder)(nil)).Elem()
+	typeStreamer      = reflect.TypeOf((*streamer)(nil)).Elem()
+	typeTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeEnvelope      = reflect.TypeOf(Envelope{})
+
+	schemaNameReplacer = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+)
+
+// openAPIGenerator describes the routes as the interceptor writes them, e.g. with the
+// response wrapper or problem details it was configured with.
+type openAPIGenerator struct {
+	it      *Interceptor
+	schemas map[string]*openapi.Schema
+	names   map[reflect.Type]string
+}
+
+func newOpenAPIGenerator(it *Interceptor) *openAPIGenerator {
+	return &openAPIGenerator{
+		it:      it,
+		schemas: map[string]*openapi.Schema{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+func (g *openAPIGenerator) document(info openapi.Info, entries []routeEntry) *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    info,
+		Paths:   map[string]openapi.PathItem{},
+	}
+	for _, entry := range entries {
+		if entry.method == http.MethodConnect {
+			continue
+		}
+		p := openAPIPath(entry.path)
+		item, ok := doc.Paths[p]
+		if !ok {
+			item = openapi.PathItem{}
+			doc.Paths[p] = item
+		}
+		item[strings.ToLower(entry.method)] = g.operation(entry)
+	}
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+func (g *openAPIGenerator) operation(entry routeEntry) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: operationID(entry.method, entry.path),
+		Responses:   map[string]*openapi.Response{},
+	}
+	if tag := strings.Trim(entry.handler.Base(), "/"); tag != "" {
+		op.Tags = []string{tag}
+	}
+	if entry.route.Meta.Name != "" {
+		op.OperationID = entry.route.Meta.Name
+	}
+	if len(entry.route.Meta.Tags) > 0 {
+		op.Tags = entry.route.Meta.Tags
+	}
+
+	ft := reflect.TypeOf(entry.route.Func)
+	if ft == nil || ft.Kind() != reflect.Func {
+		op.Responses["default"] = &openapi.Response{Description: "Response"}
+		return op
+	}
+	for i := 0; i < ft.NumIn(); i++ {
+		if ft.In(i).Kind() == reflect.Struct {
+			g.params(op, ft.In(i))
+		}
+	}
+	g.responses(op, ft, g.it.with(entry.route.Options...))
+	return op
+}
+
+func (g *openAPIGenerator) params(op *openapi.Operation, rtp reflect.Type) {
+	form := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	multipart := false
+	for i := 0; i < rtp.NumField(); i++ {
+		field := rtp.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		source, name := fieldSource(field)
+		switch source {
+		case SourcePath, SourceQuery, SourceHeader, SourceCookie:
+			schema, required := g.paramSchema(field)
+			param := openapi.Parameter{
+				Name:     name,
+				In:       source,
+				Required: required || source == SourcePath,
+				Schema:   schema,
+			}
+			if field.Type.Kind() == reflect.Slice && field.Tag.Get("sep") != "" {
+				explode := false
+				param.Explode = &explode
+			}
+			if field.Type.Kind() == reflect.Map {
+				param.Style = "deepObject"
+			}
+			op.Parameters = append(op.Parameters, param)
+		case SourceForm:
+			schema, required := g.paramSchema(field)
+			form.Properties[name] = schema
+			if required {
+				form.Required = append(form.Required, name)
+			}
+		case SourceFile:
+			multipart = true
+			schema := &openapi.Schema{Type: "string", Format: "binary"}
+			if field.Tag.Get("files") != "" {
+				schema = &openapi.Schema{Type: "array", Items: schema}
+			}
+			form.Properties[name] = schema
+			if field.Tag.Get("optional") != "true" {
+				form.Required = append(form.Required, name)
+			}
+		case SourceBody:
+			schema := g.schema(field.Type)
+			content := map[string]openapi.MediaType{}
+			if cd, ok := requestCodecs[name]; ok {
+				content[mediaType(cd.ContentType())] = openapi.MediaType{Schema: schema}
+			}
+			if name == "form" || name == "auto" {
+				content[gin.MIMEPOSTForm] = openapi.MediaType{Schema: schema}
+			}
+			if name == "auto" {
+				for _, cd := range defaultCodecs {
+					content[mediaType(cd.ContentType())] = openapi.MediaType{Schema: schema}
+				}
+			}
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: content}
+		}
+	}
+
+	if len(form.Properties) > 0 {
+		contentType := "application/x-www-form-urlencoded"
+		if multipart {
+			contentType = "multipart/form-data"
+		}
+		op.RequestBody = &openapi.RequestBody{
+			Required: len(form.Required) > 0,
+			Content: map[string]openapi.MediaType{
+				contentType: {Schema: form},
+			},
+		}
+	}
+}
+
+// paramSchema returns the schema of a bound field, non-pointer values without a default
+// fail binding when they are missing so they are reported as required.
+func (g *openAPIGenerator) paramSchema(field reflect.StructField) (*openapi.Schema, bool) {
+	rtp := field.Type
+	var schema *openapi.Schema
+	switch {
+	case rtp == typeDuration:
+		schema = &openapi.Schema{Type: "string", Format: "duration"}
+	case rtp.Kind() == reflect.Slice && rtp.Elem() == typeDuration:
+		schema = &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string", Format: "duration"}}
+	case rtp != typeTime && rtp.Kind() == reflect.Struct && reflect.PtrTo(rtp).Implements(typeTextUnmarshaler):
+		schema = &openapi.Schema{Type: "string"}
+	default:
+		schema = g.schema(rtp)
+	}
+
+	defaultVal := field.Tag.Get("default")
+	if defaultVal != "" && rtp.Kind() != reflect.Slice && rtp.Kind() != reflect.Map {
+		val := reflect.New(rtp).Elem()
+		if err := setVal(val, defaultVal, field.Tag.Get("layout")); err == nil {
+			schema.Default = val.Interface()
+		}
+	}
+
+	required := applyConstraints(schema, rtp, field.Tag.Get("validate"))
+	switch rtp.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		required = required || (defaultVal == "" && !zeroOnEmpty(rtp))
+	}
+	return schema, required
+}
+
+func (g *openAPIGenerator) responses(op *openapi.Operation, ft reflect.Type, it *Interceptor) {
+	var out reflect.Type
+	hasErr := false
+	for i := 0; i < ft.NumOut(); i++ {
+		if ft.Out(i).Implements(typeError) {
+			hasErr = true
+		} else {
+			out = ft.Out(i)
+		}
+	}
+
+	upgrade := false
+	for i := 0; i < ft.NumIn(); i++ {
+		upgrade = upgrade || ft.In(i) == typeConn
+	}
+
+	if upgrade {
+		op.Responses[strconv.Itoa(http.StatusSwitchingProtocols)] = &openapi.Response{Description: "Switching Protocols"}
+	} else if out == nil && it.responseWrapper == nil {
+		op.Responses[strconv.Itoa(http.StatusNoContent)] = &openapi.Response{Description: "No Content"}
+	} else if out == nil {
+		op.Responses[strconv.Itoa(http.StatusOK)] = &openapi.Response{
+			Description: "OK",
+			Content: map[string]openapi.MediaType{
+				"application/json": {Schema: g.wrap(it.responseWrapper, nil)},
+			},
+		}
+	} else {
+		resp := g.response(out)
+		if mt, ok := resp.Content["application/json"]; ok && it.responseWrapper != nil {
+			resp.Content["application/json"] = openapi.MediaType{Schema: g.wrap(it.responseWrapper, mt.Schema)}
+		}
+		op.Responses[strconv.Itoa(http.StatusOK)] = resp
+	}
+	if hasErr || len(op.Parameters) > 0 || op.RequestBody != nil {
+		op.Responses["default"] = g.errorResponse(it)
+	}
+}
+
+func (g *openAPIGenerator) errorResponse(it *Interceptor) *openapi.Response {
+	resp := &openapi.Response{Description: "Error"}
+	switch {
+	case it.problemDetails:
+		resp.Content = map[string]openapi.MediaType{
+			"application/problem+json": {Schema: g.schema(reflect.TypeOf(Problem{}))},
+		}
+	case it.responseWrapper != nil:
+		resp.Content = map[string]openapi.MediaType{
+			"application/json": {Schema: g.wrap(it.responseWrapper, nil)},
+		}
+	default:
+		resp.Content = map[string]openapi.MediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(HTTPError{}))},
+		}
+	}
+	return resp
+}
+
+// wrap returns the schema of data written through the response wrapper. Only the shape of
+// WrapEnvelope is known, other wrappers are described as any value.
+func (g *openAPIGenerator) wrap(w ResponseWrapper, data *openapi.Schema) *openapi.Schema {
+	if reflect.ValueOf(w).Pointer() != reflect.ValueOf(WrapEnvelope).Pointer() {
+		return &openapi.Schema{}
+	}
+	if data == nil {
+		return g.schema(typeEnvelope)
+	}
+	schema := g.structSchema(typeEnvelope)
+	schema.Properties["data"] = data
+	return schema
+}
+
+func (g *openAPIGenerator) response(rtp reflect.Type) *openapi.Response {
+	for rtp.Kind() == reflect.Ptr {
+		rtp = rtp.Elem()
+	}
+	if rtp.Implements(typeResponder) {
+		if body, ok := rtp.FieldByName("Body"); ok {
+			return g.response(body.Type)
+		}
+	}
+
+	resp := &openapi.Response{Description: "OK"}
+	if rtp.Implements(typeStreamer) || rtp.Kind() == reflect.Chan {
+		resp.Content = map[string]openapi.MediaType{
+			"text/event-stream": {Schema: &openapi.Schema{Type: "string"}},
+		}
+		return resp
+	}
+	switch rtp {
+	case typeView:
+		resp.Content = map[string]openapi.MediaType{
+			"text/html": {Schema: &openapi.Schema{Type: "string"}},
+		}
+	case typeFile:
+		resp.Content = map[string]openapi.MediaType{
+			"application/octet-stream": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+		}
+	default:
+		resp.Content = map[string]openapi.MediaType{
+			"application/json": {Schema: g.schema(rtp)},
+		}
+	}
+	return resp
+}
+
+func (g *openAPIGenerator) schema(rtp reflect.Type) *openapi.Schema {
+	for rtp.Kind() == reflect.Ptr {
+		rtp = rtp.Elem()
+	}
+	if rtp == typeTime {
+		return &openapi.Schema{Type: "string", Format: "date-time"}
+	}
+	if rtp.Kind() != reflect.Struct && reflect.PtrTo(rtp).Implements(typeTextMarshaler) {
+		return &openapi.Schema{Type: "string"}
+	}
+
+	switch rtp.Kind() {
+	case reflect.Bool:
+		return &openapi.Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &openapi.Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &openapi.Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openapi.Schema{Type: "integer", Format: "int32", Minimum: floatPtr(0)}
+	case reflect.Uint, reflect.Uint64:
+		return &openapi.Schema{Type: "integer", Format: "int64", Minimum: floatPtr(0)}
+	case reflect.Float32:
+		return &openapi.Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openapi.Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openapi.Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if rtp.Elem().Kind() == reflect.Uint8 {
+			return &openapi.Schema{Type: "string", Format: "byte"}
+		}
+		return &openapi.Schema{Type: "array", Items: g.schema(rtp.Elem())}
+	case reflect.Map:
+		return &openapi.Schema{Type: "object", AdditionalProperties: g.schema(rtp.Elem())}
+	case reflect.Struct:
+		if rtp.Name() == "" {
+			return g.structSchema(rtp)
+		}
+		return g.structRef(rtp)
+	}
+	return &openapi.Schema{}
+}
+
+func (g *openAPIGenerator) structRef(rtp reflect.Type) *openapi.Schema {
+	if name, ok := g.names[rtp]; ok {
+		return openapi.Ref(name)
+	}
+
+	name := schemaNameReplacer.ReplaceAllString(rtp.Name(), "_")
+	if _, ok := g.schemas[name]; ok {
+		pkg := rtp.PkgPath()
+		name = schemaNameReplacer.ReplaceAllString(pkg[strings.LastIndex(pkg, "/")+1:], "_") + "." + name
+	}
+	for i := 2; ; i++ {
+		if _, ok := g.schemas[name]; !ok {
+			break
+		}
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+	}
+
+	// register before building so recursive types resolve to the reference
+	g.names[rtp] = name
+	g.schemas[name] = &openapi.Schema{}
+	g.schemas[name] = g.structSchema(rtp)
+	return openapi.Ref(name)
+}
+
+func (g *openAPIGenerator) structSchema(rtp reflect.Type) *openapi.Schema {
+	schema := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	for i := 0; i < rtp.NumField(); i++ {
+		field := rtp.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded := g.structSchema(ft)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		prop := g.schema(field.Type)
+		if applyConstraints(prop, field.Type, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// applyConstraints maps validate tags to schema keywords and reports whether the value is required.
+func applyConstraints(schema *openapi.Schema, rtp reflect.Type, tag string) bool {
+	for rtp.Kind() == reflect.Ptr {
+		rtp = rtp.Elem()
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(rule, "=")
+		if key == "dive" {
+			break
+		}
+		if key == "required" {
+			required = true
+			continue
+		}
+		if schema.Ref != "" {
+			continue
+		}
+
+		switch key {
+		case "min", "gte":
+			setMin(schema, rtp, val, false)
+		case "max", "lte":
+			setMax(schema, rtp, val, false)
+		case "gt":
+			setMin(schema, rtp, val, true)
+		case "lt":
+			setMax(schema, rtp, val, true)
+		case "len":
+			setMin(schema, rtp, val, false)
+			setMax(schema, rtp, val, false)
+		case "oneof":
+			for _, v := range strings.Fields(val) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && schema.Type != "string" {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, v)
+				}
+			}
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid", "uuid4":
+			schema.Format = "uuid"
+		}
+	}
+	return required
+}
+
+func setMin(schema *openapi.Schema, rtp reflect.Type, val string, exclusive bool) {
+	n, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return
+	}
+	switch rtp.Kind() {
+	case reflect.String:
+		schema.MinLength = intPtr(n)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		schema.MinItems = intPtr(n)
+	default:
+		if exclusive {
+			schema.ExclusiveMinimum = floatPtr(n)
+		} else {
+			schema.Minimum = floatPtr(n)
+		}
+	}
+}
+
+func setMax(schema *openapi.Schema, rtp reflect.Type, val string, exclusive bool) {
+	n, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return
+	}
+	switch rtp.Kind() {
+	case reflect.String:
+		schema.MaxLength = intPtr(n)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		schema.MaxItems = intPtr(n)
+	default:
+		if exclusive {
+			schema.ExclusiveMaximum = floatPtr(n)
+		} else {
+			schema.Maximum = floatPtr(n)
+		}
+	}
+}
+
+func floatPtr(n float64) *float64 {
+	return &n
+}
+
+func intPtr(n float64) *int {
+	i := int(n)
+	return &i
+}
+
+// openAPIPath converts gin path params like :id and *path to {id} and {path}
+func openAPIPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func operationID(method, p string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(p, "/") {
+		segment = strings.TrimLeft(segment, ":*")
+		if segment != "" {
+			id += "_" + schemaNameReplacer.ReplaceAllString(segment, "_")
+		}
+	}
+	return id
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"encoding/json"
+	"github.com/sakuradon99/gokit/web/openapi"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name" validate:"required,max=32"`
+	Role      string    `json:"role,omitempty" validate:"oneof=admin user"`
+	CreatedAt time.Time `json:"created_at"`
+	Friends   []*testUser
+	secret    string
+}
+
+type testUserHandler struct{}
+
+func (h *testUserHandler) Base() string {
+	return "users"
+}
+
+func (h *testUserHandler) Routes() []Route {
+	return Routes(
+		Get(":id", func(p struct {
+			ID     int64    `path:"id"`
+			Fields []string `query:"fields" sep:","`
+			Tenant string   `header:"X-Tenant-ID" validate:"required"`
+		}) (*testUser, error) {
+			return nil, nil
+		}),
+		Get("", func(p struct {
+			Page   int  `query:"page" default:"1" validate:"min=1"`
+			Size   int  `query:"size"`
+			Active bool `query:"active"`
+		}) ([]testUser, error) {
+			return nil, nil
+		}),
+		Post("", func(p struct {
+			Body testUser `request:"json"`
+		}) (Response[testUser], error) {
+			return Response[testUser]{}, nil
+		}),
+		Post(":id/avatar", func(p struct {
+			ID     int64                 `path:"id"`
+			Avatar *multipart.FileHeader `file:"avatar"`
+			Note   string                `form:"note"`
+		}) error {
+			return nil
+		}),
+	)
+}
+
+func Test_OpenAPI(t *testing.T) {
+	s := &Server{
+		handlers:       []Handler{&testUserHandler{}},
+		openAPITitle:   "test",
+		openAPIVersion: "1.0.0",
+	}
+	doc := s.OpenAPI()
+
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	assert.Equal(t, openapi.Info{Title: "test", Version: "1.0.0"}, doc.Info)
+
+	get := doc.Paths["/users/{id}"]["get"]
+	assert.Equal(t, "get_users_id", get.OperationID)
+	assert.Equal(t, []string{"users"}, get.Tags)
+	assert.Equal(t, 3, len(get.Parameters))
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "path", get.Parameters[0].In)
+	assert.True(t, get.Parameters[0].Required)
+	assert.Equal(t, "array", get.Parameters[1].Schema.Type)
+	assert.False(t, *get.Parameters[1].Explode)
+	assert.Equal(t, "header", get.Parameters[2].In)
+	assert.True(t, get.Parameters[2].Required)
+	assert.Equal(t, openapi.Ref("testUser"), get.Responses["200"].Content["application/json"].Schema)
+	assert.Equal(t, openapi.Ref("HTTPError"), get.Responses["default"].Content["application/json"].Schema)
+
+	list := doc.Paths["/users"]["get"]
+	assert.Equal(t, 1, list.Parameters[0].Schema.Default)
+	assert.Equal(t, 1.0, *list.Parameters[0].Schema.Minimum)
+	assert.False(t, list.Parameters[0].Required)
+	assert.True(t, list.Parameters[1].Required)
+	assert.False(t, list.Parameters[2].Required)
+	assert.Equal(t, "array", list.Responses["200"].Content["application/json"].Schema.Type)
+
+	create := doc.Paths["/users"]["post"]
+	assert.Equal(t, openapi.Ref("testUser"), create.RequestBody.Content["application/json"].Schema)
+	assert.Equal(t, openapi.Ref("testUser"), create.Responses["200"].Content["application/json"].Schema)
+
+	avatar := doc.Paths["/users/{id}/avatar"]["post"]
+	form := avatar.RequestBody.Content["multipart/form-data"].Schema
+	assert.Equal(t, "binary", form.Properties["avatar"].Format)
+	assert.Equal(t, "string", form.Properties["note"].Type)
+	assert.Equal(t, []string{"avatar"}, form.Required)
+	assert.NotNil(t, avatar.Responses["204"])
+
+	user := doc.Components.Schemas["testUser"]
+	assert.Equal(t, []string{"name"}, user.Required)
+	assert.Equal(t, 32, *user.Properties["name"].MaxLength)
+	assert.Equal(t, []any{"admin", "user"}, user.Properties["role"].Enum)
+	assert.Equal(t, "date-time", user.Properties["created_at"].Format)
+	assert.Equal(t, openapi.Ref("testUser"), user.Properties["Friends"].Items)
+	assert.Nil(t, user.Properties["secret"])
+
+	file := filepath.Join(t.TempDir(), "openapi.json")
+	assert.Nil(t, s.ExportOpenAPI(file))
+	b, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	var exported map[string]any
+	assert.Nil(t, json.Unmarshal(b, &exported))
+	assert.Equal(t, openapi.Version, exported["openapi"])
+}
+
+type testEnvelopeHandler struct{}
+
+func (h *testEnvelopeHandler) Base() string {
+	return "envelope"
+}
+
+func (h *testEnvelopeHandler) Routes() []Route {
+	return Routes(
+		Get(":id", func(p struct {
+			ID int64 `path:"id"`
+		}) (*testUser, error) {
+			return nil, nil
+		}),
+		Delete(":id", func(p struct {
+			ID int64 `path:"id"`
+		}) error {
+			return nil
+		}),
+		Get("raw", func() (testUser, error) {
+			return testUser{}, nil
+		}).With(WithRawResponse(), WithProblemDetails()),
+	)
+}
+
+func Test_OpenAPIInterceptorOptions(t *testing.T) {
+	s := &Server{
+		handlers:           []Handler{&testEnvelopeHandler{}},
+		interceptorConfigs: []ServerInterceptorConfig{testEnvelopeConfig{}},
+	}
+	doc := s.OpenAPI()
+
+	get := doc.Paths["/envelope/{id}"]["get"]
+	data := get.Responses["200"].Content["application/json"].Schema
+	assert.Equal(t, openapi.Ref("testUser"), data.Properties["data"])
+	assert.Equal(t, "integer", data.Properties["code"].Type)
+	assert.Equal(t, openapi.Ref("Envelope"), get.Responses["default"].Content["application/json"].Schema)
+
+	del := doc.Paths["/envelope/{id}"]["delete"]
+	assert.Nil(t, del.Responses["204"])
+	assert.Equal(t, openapi.Ref("Envelope"), del.Responses["200"].Content["application/json"].Schema)
+
+	raw := doc.Paths["/envelope/raw"]["get"]
+	assert.Equal(t, openapi.Ref("testUser"), raw.Responses["200"].Content["application/json"].Schema)
+	assert.Equal(t, openapi.Ref("Problem"), raw.Responses["default"].Content["application/problem+json"].Schema)
+}
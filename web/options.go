@@ -13,3 +13,52 @@ func WithViewIntercept(f viewIntercept) Options {
 		i.viewIntercept = f
 	}
 }
+
+// WithErrorHandler replaces the way errors are written, the error is still recorded with c.Error.
+func WithErrorHandler(h ErrorHandler) Options {
+	return func(i *Interceptor) {
+		i.errorHandler = h
+	}
+}
+
+// WithErrorMapper maps errors to HTTPError before MapError, returning nil falls back to MapError.
+func WithErrorMapper(m ErrorMapper) Options {
+	return func(i *Interceptor) {
+		i.errorMapper = m
+	}
+}
+
+// WithProblemDetails writes errors as RFC 7807 application/problem+json.
+func WithProblemDetails() Options {
+	return func(i *Interceptor) {
+		i.problemDetails = true
+	}
+}
+
+// WithResponseWrapper wraps JSON responses and mapped errors, see WrapEnvelope.
+func WithResponseWrapper(w ResponseWrapper) Options {
+	return func(i *Interceptor) {
+		i.responseWrapper = w
+	}
+}
+
+// WithRawResponse disables the response wrapper, it is meant to be used per route.
+func WithRawResponse() Options {
+	return func(i *Interceptor) {
+		i.responseWrapper = nil
+	}
+}
+
+func WithWebSocketConfig(cfg WebSocketConfig) Options {
+	return func(i *Interceptor) {
+		i.webSocket = cfg
+	}
+}
+
+// WithCodecs adds codecs for request bodies and enables them for responses, they take
+// precedence over the default ones of the same content type, e.g. WithCodecs(XMLCodec).
+func WithCodecs(codecs ...Codec) Options {
+	return func(i *Interceptor) {
+		i.extraCodecs = append(append([]Codec{}, i.extraCodecs...), codecs...)
+	}
+}
@@ -1,27 +1,34 @@
 package web
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"net/http"
 	"reflect"
+	"strings"
 )
 
-var validate = validator.New()
+var validate = newValidate()
 
-type paramBuilder interface {
-	Build(ctx *gin.Context) (reflect.Value, error)
-}
-
-type defaultParamBuilder struct {
-	rtp reflect.Type
-}
-
-func newDefaultParamBuilder(rtp reflect.Type) *defaultParamBuilder {
-	return &defaultParamBuilder{rtp: rtp}
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		if source, name := fieldSource(field); source != "" && source != SourceBody {
+			return name
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
 }
 
-func (b *defaultParamBuilder) Build(_ *gin.Context) (reflect.Value, error) {
-	return reflect.New(b.rtp).Elem(), nil
+type paramBuilder interface {
+	Build(ctx *gin.Context) (reflect.Value, error)
 }
 
 type contextParamBuilder struct {
@@ -36,20 +43,115 @@ func (b *contextParamBuilder) Build(ctx *gin.Context) (reflect.Value, error) {
 }
 
 type structParamBuilder struct {
-	rtp     reflect.Type
-	binders []binder
+	rtp        reflect.Type
+	binders    []binder
+	validators []int
 }
 
 func newStructParamBuilder(rtp reflect.Type, binders []binder) *structParamBuilder {
-	return &structParamBuilder{rtp: rtp, binders: binders}
+	// fields implementing Validator are checked after tag validation
+	var validators []int
+	for i := 0; i < rtp.NumField(); i++ {
+		field := rtp.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Implements(typeValidator) || reflect.PtrTo(field.Type).Implements(typeValidator) {
+			validators = append(validators, i)
+		}
+	}
+	return &structParamBuilder{rtp: rtp, binders: binders, validators: validators}
 }
 
 func (b *structParamBuilder) Build(ctx *gin.Context) (reflect.Value, error) {
 	param := reflect.New(b.rtp).Elem()
+	var bindErrs BindErrors
 	for _, binder := range b.binders {
-		if err := binder.Bind(ctx, param); err != nil {
+		err := binder.Bind(ctx, param)
+		if err == nil {
+			continue
+		}
+		// the body is unusable once its limit is exceeded
+		var bindErr *BindError
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &bindErr) || errors.As(err, &maxBytesErr) {
 			return reflect.Value{}, err
 		}
+		bindErrs = append(bindErrs, bindErr)
+	}
+	if len(bindErrs) > 0 {
+		return reflect.Value{}, bindErrs
+	}
+	if err := b.validate(param); err != nil {
+		return reflect.Value{}, err
 	}
 	return param, nil
 }
+
+func (b *structParamBuilder) validate(param reflect.Value) error {
+	if err := validate.Struct(param.Interface()); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return err
+		}
+		return b.validationErrors(validationErrs)
+	}
+	for _, i := range b.validators {
+		field := param.Field(i)
+		if field.Kind() != reflect.Ptr {
+			field = field.Addr()
+		} else if field.IsNil() {
+			continue
+		}
+		if err := field.Interface().(Validator).Validate(); err != nil {
+			source, name := fieldSource(b.rtp.Field(i))
+			if source == SourceBody {
+				name = ""
+			}
+			return BindErrors{{Source: source, Field: name, Reason: ReasonValidate, Err: err}}
+		}
+	}
+	if v, ok := param.Addr().Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return BindErrors{{Reason: ReasonValidate, Err: err}}
+		}
+	}
+	return nil
+}
+
+func (b *structParamBuilder) validationErrors(errs validator.ValidationErrors) BindErrors {
+	// namespaces look like "Param.Field.Nested", anonymous params have no "Param"
+	top := 1
+	if b.rtp.Name() == "" {
+		top = 0
+	}
+	bindErrs := make(BindErrors, 0, len(errs))
+	for _, fe := range errs {
+		// the top level field decides the source
+		goNames := strings.Split(fe.StructNamespace(), ".")[top:]
+		names := strings.Split(fe.Namespace(), ".")[top:]
+		bindErr := &BindError{
+			Field:  strings.Join(names, "."),
+			Reason: ReasonValidate,
+			Tag:    fe.Tag(),
+			Err:    fe,
+		}
+		if field, ok := b.rtp.FieldByName(goNames[0]); ok {
+			bindErr.Source, _ = fieldSource(field)
+			if bindErr.Source == SourceBody {
+				bindErr.Field = strings.Join(names[1:], ".")
+			}
+		}
+		if fe.Param() != "" {
+			bindErr.Tag += "=" + fe.Param()
+		}
+		if fe.Tag() == "required" {
+			bindErr.Reason = ReasonRequired
+		}
+		if fe.Kind() != reflect.Struct && fe.Kind() != reflect.Invalid {
+			bindErr.Value = fmt.Sprint(fe.Value())
+		}
+		bindErrs = append(bindErrs, bindErr)
+	}
+	return bindErrs
+}
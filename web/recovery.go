@@ -0,0 +1,55 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/logger"
+	"net/http"
+	"runtime/debug"
+	"syscall"
+)
+
+// writeErrorLog is replaced in tests
+var writeErrorLog = logger.Error
+
+// Recovery recovers panics of the following handlers, logs them with logger.Error and
+// writes the mapped error like a handler error would be.
+func Recovery(options ...Options) gin.HandlerFunc {
+	return NewInterceptor(options...).Recovery()
+}
+
+func (it *Interceptor) Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			fields := []logger.LogField{
+				logger.Field("method", c.Request.Method),
+				logger.Field("path", c.Request.URL.Path),
+				logger.Field("panic", err),
+			}
+			// the client went away, there is nobody to respond to
+			if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+				writeErrorLog(c, "connection lost", fields...)
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+
+			writeErrorLog(c, "panic recovered", append(fields, logger.Field("stack", string(debug.Stack())))...)
+			it.handleError(c, fmt.Errorf("panic: %w", err))
+		}()
+		c.Next()
+	}
+}
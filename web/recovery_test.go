@@ -0,0 +1,74 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/logger"
+	"github.com/sakuradon99/gokit/trace"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testPanicHandler struct{}
+
+func (h *testPanicHandler) Base() string {
+	return ""
+}
+
+func (h *testPanicHandler) Routes() []Route {
+	return Routes(
+		Get("panic", func() string {
+			panic("boom")
+		}),
+	)
+}
+
+func Test_Recovery(t *testing.T) {
+	var messages []string
+	var fields map[string]any
+	writeErrorLog = func(ctx context.Context, message string, kv ...logger.LogField) {
+		messages = append(messages, message+" "+trace.GetTraceID(ctx))
+		fields = getFields(kv)
+	}
+	t.Cleanup(func() {
+		writeErrorLog = logger.Error
+	})
+
+	s := newTestServer(&testPanicHandler{})
+	s.interceptorConfigs = []ServerInterceptorConfig{testEnvelopeConfig{}}
+	for _, mode := range []string{"", "default", "new"} {
+		messages = nil
+		s.engineMode = mode
+		assert.Nil(t, s.Init())
+		engine, err := s.engine()
+		assert.Nil(t, err)
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code, mode)
+		assert.Equal(t, gin.H{"code": float64(500), "msg": "internal server error", "data": nil, "trace_id": w.Header().Get("X-Trace-ID")},
+			decode[gin.H](t, w))
+		assert.Equal(t, []string{"panic recovered " + w.Header().Get("X-Trace-ID")}, messages, mode)
+		assert.Equal(t, "boom", fields["panic"])
+		assert.Contains(t, fields["stack"], "runtime/debug.Stack")
+	}
+
+	s.engineMode = "gin"
+	assert.NotNil(t, s.Init())
+}
+
+func getFields(kv []logger.LogField) map[string]any {
+	fields := map[string]any{}
+	for _, field := range kv {
+		fields[field.Key] = field.Value
+	}
+	return fields
+}
+
+type testEnvelopeConfig struct{}
+
+func (testEnvelopeConfig) InterceptorOptions() []Options {
+	return []Options{WithResponseWrapper(WrapEnvelope)}
+}
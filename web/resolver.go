@@ -0,0 +1,64 @@
+package web
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"reflect"
+)
+
+// resolver supplies handler params of a registered type, usually from values that
+// middlewares set on the gin context.
+type resolver func(c *gin.Context) (reflect.Value, error)
+
+// WithResolver supplies handler params of type T, e.g. func(p Params, principal *auth.Principal).
+// Errors returned by resolve are handled like handler errors.
+func WithResolver[T any](resolve func(c *gin.Context) (T, error)) Options {
+	rtp := reflect.TypeOf((*T)(nil)).Elem()
+	return withResolver(rtp, func(c *gin.Context) (reflect.Value, error) {
+		v, err := resolve(c)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(&v).Elem(), nil
+	})
+}
+
+// WithContextKey supplies handler params of type T from the gin context key set by a
+// middleware, a missing key is an internal error unless T is a pointer, which is then nil.
+func WithContextKey[T any](key string) Options {
+	return WithResolver(func(c *gin.Context) (T, error) {
+		var zero T
+		v, ok := c.Get(key)
+		if !ok || v == nil {
+			if reflect.TypeOf((*T)(nil)).Elem().Kind() == reflect.Ptr {
+				return zero, nil
+			}
+			return zero, fmt.Errorf("context key %q is not set", key)
+		}
+		t, ok := v.(T)
+		if !ok {
+			return zero, fmt.Errorf("context key %q holds %T, not %T", key, v, zero)
+		}
+		return t, nil
+	})
+}
+
+func withResolver(rtp reflect.Type, r resolver) Options {
+	return func(i *Interceptor) {
+		// copied as interceptors share the map with their clones
+		resolvers := make(map[reflect.Type]resolver, len(i.resolvers)+1)
+		for k, v := range i.resolvers {
+			resolvers[k] = v
+		}
+		resolvers[rtp] = r
+		i.resolvers = resolvers
+	}
+}
+
+type resolverParamBuilder struct {
+	resolve resolver
+}
+
+func (b *resolverParamBuilder) Build(c *gin.Context) (reflect.Value, error) {
+	return b.resolve(c)
+}
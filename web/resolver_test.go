@@ -0,0 +1,62 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testPrincipal struct {
+	User string
+}
+
+type testTenantID string
+
+func Test_Resolver(t *testing.T) {
+	it := NewInterceptor(
+		WithContextKey[*testPrincipal]("principal"),
+		WithResolver(func(c *gin.Context) (testTenantID, error) {
+			if tenant := c.GetHeader("X-Tenant-ID"); tenant != "" {
+				return testTenantID(tenant), nil
+			}
+			return "", NewHTTPError(http.StatusBadRequest, 1, "missing tenant")
+		}),
+	)
+	f := func(p *testPrincipal, tenant testTenantID) string {
+		if p == nil {
+			return "anonymous@" + string(tenant)
+		}
+		return p.User + "@" + string(tenant)
+	}
+
+	engine := gin.New()
+	engine.GET("/", func(c *gin.Context) {
+		if c.Query("user") != "" {
+			c.Set("principal", &testPrincipal{User: c.Query("user")})
+		}
+	}, it.Intercept(f))
+
+	for _, tt := range []struct {
+		url, tenant string
+		status      int
+		body        string
+	}{
+		{"/?user=u1", "t1", http.StatusOK, `"u1@t1"`},
+		{"/", "t1", http.StatusOK, `"anonymous@t1"`},
+		{"/?user=u1", "", http.StatusBadRequest, `{"code":1,"message":"missing tenant"}`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		if tt.tenant != "" {
+			req.Header.Set("X-Tenant-ID", tt.tenant)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code)
+		assert.Equal(t, tt.body, w.Body.String())
+	}
+
+	_, err := NewInterceptor().intercept(f)
+	assert.EqualError(t, err, "no resolver for param type *web.testPrincipal")
+}
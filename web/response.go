@@ -0,0 +1,65 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type responder interface {
+	apply(c *gin.Context) (int, any)
+}
+
+// Response lets a handler control the status code, headers and cookies written along with Body.
+// Body is written the same way as a plain return value, a zero Status means 200.
+type Response[T any] struct {
+	Status  int
+	Header  http.Header
+	Cookies []*http.Cookie
+	Body    T
+}
+
+func NewResponse[T any](status int, body T) Response[T] {
+	return Response[T]{
+		Status: status,
+		Body:   body,
+	}
+}
+
+func Created[T any](location string, body T) Response[T] {
+	return NewResponse(http.StatusCreated, body).WithHeader("Location", location)
+}
+
+func Accepted[T any](body T) Response[T] {
+	return NewResponse(http.StatusAccepted, body)
+}
+
+func (r Response[T]) WithHeader(key, value string) Response[T] {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Add(key, value)
+	r.Header = header
+	return r
+}
+
+func (r Response[T]) WithCookie(cookie *http.Cookie) Response[T] {
+	r.Cookies = append(append([]*http.Cookie{}, r.Cookies...), cookie)
+	return r
+}
+
+func (r Response[T]) apply(c *gin.Context) (int, any) {
+	for key, values := range r.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+	for _, cookie := range r.Cookies {
+		http.SetCookie(c.Writer, cookie)
+	}
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return status, r.Body
+}
@@ -1,11 +1,83 @@
 package web
 
-import "net/http"
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
 
+// MethodAny registers a route for every http method.
+const MethodAny = "ANY"
+
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// Route is either a handler route or, when Routes is set, a group of routes sharing
+// the path prefix, middlewares and options.
 type Route struct {
-	Path   string
-	Method string
-	Func   any
+	Path        string
+	Method      string
+	Func        any
+	Options     []Options
+	Middlewares []gin.HandlerFunc
+	Routes      []Route
+	Meta        RouteMeta
+}
+
+// With returns a copy of the route whose handler is intercepted with extra options.
+func (r Route) With(options ...Options) Route {
+	r.Options = append(append([]Options{}, r.Options...), options...)
+	return r
+}
+
+// Use returns a copy of the route running extra middlewares before its handler,
+// middlewares of a group run before the ones of its routes.
+func (r Route) Use(middlewares ...gin.HandlerFunc) Route {
+	r.Middlewares = append(append([]gin.HandlerFunc{}, r.Middlewares...), middlewares...)
+	return r
+}
+
+func (r Route) WithName(name string) Route {
+	r.Meta.Name = name
+	return r
+}
+
+func (r Route) WithTags(tags ...string) Route {
+	r.Meta.Tags = append(append([]string{}, r.Meta.Tags...), tags...)
+	return r
+}
+
+func (r Route) WithRoles(roles ...string) Route {
+	r.Meta.Roles = append(append([]string{}, r.Meta.Roles...), roles...)
+	return r
+}
+
+func (r Route) WithRateLimit(class string) Route {
+	r.Meta.RateLimit = class
+	return r
+}
+
+func (r Route) WithValue(key string, val any) Route {
+	values := make(map[string]any, len(r.Meta.Values)+1)
+	for k, v := range r.Meta.Values {
+		values[k] = v
+	}
+	values[key] = val
+	r.Meta.Values = values
+	return r
+}
+
+func (r Route) isGroup() bool {
+	return r.Func == nil && r.Method == ""
+}
+
+func (r Route) methods() []string {
+	if r.Method == MethodAny {
+		return anyMethods
+	}
+	return []string{r.Method}
 }
 
 func Get(path string, f any) Route {
@@ -40,6 +112,57 @@ func Delete(path string, f any) Route {
 	}
 }
 
+func Patch(path string, f any) Route {
+	return Route{
+		Path:   path,
+		Method: http.MethodPatch,
+		Func:   f,
+	}
+}
+
+func Head(path string, f any) Route {
+	return Route{
+		Path:   path,
+		Method: http.MethodHead,
+		Func:   f,
+	}
+}
+
+// OptionsRoute registers an OPTIONS route, Options is already taken by interceptor options.
+func OptionsRoute(path string, f any) Route {
+	return Route{
+		Path:   path,
+		Method: http.MethodOptions,
+		Func:   f,
+	}
+}
+
+func Any(path string, f any) Route {
+	return Route{
+		Path:   path,
+		Method: MethodAny,
+		Func:   f,
+	}
+}
+
+// WebSocket registers a GET route upgraded to a WebSocket connection, f receives the bound
+// params and a *Conn, e.g. func(p Params, conn *Conn) error. The connection is closed
+// when f returns, with an internal error close code if f returned an error.
+func WebSocket(path string, f any) Route {
+	return Route{
+		Path:   path,
+		Method: http.MethodGet,
+		Func:   f,
+	}
+}
+
+func Group(prefix string, routes ...Route) Route {
+	return Route{
+		Path:   prefix,
+		Routes: routes,
+	}
+}
+
 func Routes(routes ...Route) []Route {
 	return routes
 }
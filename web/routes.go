@@ -0,0 +1,51 @@
+package web
+
+import (
+	"context"
+	"github.com/sakuradon99/gokit/logger"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// RouteRecord is an entry of the route table, Middlewares are listed in the order they run:
+// the engine handlers, the route info, Middleware and RouteMiddleware beans, then the group
+// and route middlewares.
+type RouteRecord struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares"`
+}
+
+// RouteTable returns the routes registered by the last Run or Serve sorted by path.
+func (s *Server) RouteTable() []RouteRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]RouteRecord{}, s.routeTable...)
+}
+
+func (s *Server) logRouteTable() {
+	ctx := context.Background()
+	for _, record := range s.RouteTable() {
+		logger.Info(ctx, "route "+record.Method+" "+record.Path,
+			logger.Field("handler", record.Handler),
+			logger.Field("middlewares", strings.Join(record.Middlewares, ",")),
+		)
+	}
+}
+
+func funcName(f any) string {
+	fv := reflect.ValueOf(f)
+	if !fv.IsValid() {
+		return ""
+	}
+	if fv.Kind() != reflect.Func {
+		return reflect.TypeOf(f).String()
+	}
+	if fn := runtime.FuncForPC(fv.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return fv.Type().String()
+}
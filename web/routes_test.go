@@ -0,0 +1,58 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testMark(c *gin.Context) {}
+
+type testRecordHandler struct{}
+
+func (h *testRecordHandler) Base() string {
+	return "records"
+}
+
+func (h *testRecordHandler) list() []string {
+	return nil
+}
+
+func (h *testRecordHandler) Routes() []Route {
+	return Routes(
+		Get("", h.list).Use(testMark),
+		Delete(":id", func() {}),
+	)
+}
+
+func Test_RouteTable(t *testing.T) {
+	s := newTestServer(&testRecordHandler{})
+	s.routesPath = "/debug/routes"
+	s.middlewares = []Middleware{&testPathMiddleware{}}
+	engine, err := s.engine()
+	assert.Nil(t, err)
+
+	table := s.RouteTable()
+	assert.Equal(t, 2, len(table))
+	assert.Equal(t, http.MethodGet, table[0].Method)
+	assert.Equal(t, "/records", table[0].Path)
+	assert.True(t, strings.HasSuffix(table[0].Handler, "(*testRecordHandler).list-fm"), table[0].Handler)
+	middlewares := table[0].Middlewares
+	assert.Equal(t, len(engine.Handlers)+3, len(middlewares))
+	for i, h := range engine.Handlers {
+		assert.Equal(t, funcName(h), middlewares[i])
+	}
+	assert.Equal(t, []string{
+		"github.com/sakuradon99/gokit/web.routeInfoMiddleware.func1",
+		"*web.testPathMiddleware",
+		"github.com/sakuradon99/gokit/web.testMark",
+	}, middlewares[len(engine.Handlers):])
+	assert.Equal(t, "/records/:id", table[1].Path)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	assert.Equal(t, table, decode[[]RouteRecord](t, w))
+}
@@ -1,12 +1,24 @@
 package web
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/trace"
+	"github.com/sakuradon99/gokit/web/openapi"
 	"github.com/sakuradon99/ioc"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
 	"path"
 	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 var _ = ioc.Register[Server]()
@@ -15,9 +27,55 @@ type Server struct {
 	host string `value:"web.host;optional"`
 	port string `value:"web.port;optional"`
 
+	openAPIPath    string `value:"web.openapi.path;optional"`
+	openAPITitle   string `value:"web.openapi.title;optional"`
+	openAPIVersion string `value:"web.openapi.version;optional"`
+	routesPath     string `value:"web.debug.routes_path;optional"`
+
+	shutdownTimeoutStr   string `value:"web.shutdown_timeout;optional"`
+	readTimeoutStr       string `value:"web.read_timeout;optional"`
+	readHeaderTimeoutStr string `value:"web.read_header_timeout;optional"`
+	writeTimeoutStr      string `value:"web.write_timeout;optional"`
+	idleTimeoutStr       string `value:"web.idle_timeout;optional"`
+	maxHeaderBytes       int    `value:"web.max_header_bytes;optional"`
+	tlsCertFile          string `value:"web.tls.cert_file;optional"`
+	tlsKeyFile           string `value:"web.tls.key_file;optional"`
+	unixSocket           string `value:"web.unix_socket;optional"`
+	h2c                  bool   `value:"web.h2c;optional"`
+	maxBodyBytes         int    `value:"web.max_body_bytes;optional"`
+	requestTimeoutStr    string `value:"web.request_timeout;optional"`
+
+	// engineMode is default for gin's request logger, or new to drop it, panics are always
+	// handled by Recovery with trace ids and the access log replaces gin's logger
+	engineMode string `value:"web.engine;optional"`
+	// accessLog replaces gin's logger with AccessLog, headers and redact are comma separated
+	accessLog        bool   `value:"web.access_log.enabled;optional"`
+	accessLogHeaders string `value:"web.access_log.headers;optional"`
+	accessLogBody    bool   `value:"web.access_log.body;optional"`
+	accessLogRedact  string `value:"web.access_log.redact;optional"`
+
+	shutdownTimeout   time.Duration
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	requestTimeout    time.Duration
+
 	handlers            []Handler                  `inject:"r:.*"`
 	middlewares         []Middleware               `inject:"r:.*"`
+	routeMiddlewares    []RouteMiddleware          `inject:"r:.*"`
 	customEngineConfigs []ServerCustomEngineConfig `inject:"r:.*"`
+	interceptorConfigs  []ServerInterceptorConfig  `inject:"r:.*"`
+	startHooks          []ServerStartHook          `inject:"r:.*"`
+	stopHooks           []ServerStopHook           `inject:"r:.*"`
+
+	mu           sync.Mutex
+	routeTable   []RouteRecord
+	httpServer   *http.Server
+	shutdownOnce sync.Once
+	shutdown     bool
+	shutdownErr  error
+	shutdownDone chan struct{}
 }
 
 func (s *Server) Init() error {
@@ -27,60 +85,434 @@ func (s *Server) Init() error {
 	if s.port == "" {
 		s.port = "8080"
 	}
+	if s.openAPITitle == "" {
+		s.openAPITitle = "API"
+	}
+	if s.openAPIVersion == "" {
+		s.openAPIVersion = "1.0.0"
+	}
+	if s.shutdownTimeoutStr == "" {
+		s.shutdownTimeoutStr = "30s"
+	}
+	if s.engineMode != "" && s.engineMode != "default" && s.engineMode != "new" {
+		return fmt.Errorf("web.engine must be default or new, got %q", s.engineMode)
+	}
+	if (s.tlsCertFile == "") != (s.tlsKeyFile == "") {
+		return errors.New("web.tls.cert_file and web.tls.key_file must be set together")
+	}
+
+	durations := []struct {
+		key string
+		val string
+		dst *time.Duration
+	}{
+		{"web.shutdown_timeout", s.shutdownTimeoutStr, &s.shutdownTimeout},
+		{"web.read_timeout", s.readTimeoutStr, &s.readTimeout},
+		{"web.read_header_timeout", s.readHeaderTimeoutStr, &s.readHeaderTimeout},
+		{"web.write_timeout", s.writeTimeoutStr, &s.writeTimeout},
+		{"web.idle_timeout", s.idleTimeoutStr, &s.idleTimeout},
+		{"web.request_timeout", s.requestTimeoutStr, &s.requestTimeout},
+	}
+	for _, d := range durations {
+		if d.val == "" {
+			continue
+		}
+		val, err := time.ParseDuration(d.val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.key, err)
+		}
+		*d.dst = val
+	}
 	return nil
 }
 
+// Run listens on web.unix_socket or web.host:web.port and serves until the server is shut down.
 func (s *Server) Run() error {
-	wi := NewInterceptor()
-	server := gin.Default()
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+func (s *Server) listen() (net.Listener, error) {
+	if s.unixSocket != "" {
+		// a socket file left by a previous process makes listen fail, other files are kept
+		info, err := os.Lstat(s.unixSocket)
+		switch {
+		case err == nil && info.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("%s exists and is not a unix socket", s.unixSocket)
+		case err == nil:
+			if err := os.Remove(s.unixSocket); err != nil {
+				return nil, err
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+		return net.Listen("unix", s.unixSocket)
+	}
+	return net.Listen("tcp", net.JoinHostPort(s.host, s.port))
+}
+
+// Serve serves on l until the server is shut down, SIGINT and SIGTERM shut it down gracefully
+// waiting at most web.shutdown_timeout for in-flight requests.
+func (s *Server) Serve(l net.Listener) error {
+	if s.isShutdown() {
+		return s.closeAfterShutdown(l)
+	}
+	engine, err := s.engine()
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+
+	for i, hook := range s.startHooks {
+		if err := hook.OnStart(context.Background()); err != nil {
+			_ = l.Close()
+			stopStarted(s.startHooks[:i])
+			return err
+		}
+	}
+
+	httpServer := s.newHTTPServer(engine)
+	s.mu.Lock()
+	shutdown := s.shutdown
+	if !shutdown {
+		s.httpServer = httpServer
+	}
+	s.mu.Unlock()
+	if shutdown {
+		return s.closeAfterShutdown(l)
+	}
+
+	s.logRouteTable()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if s.tlsCertFile != "" {
+			errCh <- httpServer.ServeTLS(l, s.tlsCertFile, s.tlsKeyFile)
+			return
+		}
+		errCh <- httpServer.Serve(l)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			// Serve returns as soon as Shutdown starts, wait for the drain and the stop hooks
+			<-s.done()
+			return s.shutdownErr
+		}
+		_ = s.Shutdown(context.Background())
+		return err
+	case <-sigCh:
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+		return s.Shutdown(ctx)
+	}
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+// closeAfterShutdown closes l when Shutdown was called before serving started
+func (s *Server) closeAfterShutdown(l net.Listener) error {
+	_ = l.Close()
+	<-s.done()
+	return s.shutdownErr
+}
+
+// stopStarted stops the start hooks that also implement ServerStopHook in reverse order
+func stopStarted(hooks []ServerStartHook) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if hook, ok := hooks[i].(ServerStopHook); ok {
+			_ = hook.OnStop(context.Background())
+		}
+	}
+}
+
+func (s *Server) newHTTPServer(engine *gin.Engine) *http.Server {
+	// h2c only applies to plain text connections, TLS negotiates http2 by itself
+	engine.UseH2C = s.h2c && s.tlsCertFile == ""
+	return &http.Server{
+		Handler:           engine.Handler(),
+		ReadTimeout:       s.readTimeout,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
+		MaxHeaderBytes:    s.maxHeaderBytes,
+	}
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones until ctx is done
+// and then runs the stop hooks in reverse order. Serve returns at once when called after it.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		defer close(s.done())
+
+		s.mu.Lock()
+		s.shutdown = true
+		httpServer := s.httpServer
+		s.mu.Unlock()
+
+		if httpServer != nil {
+			s.shutdownErr = httpServer.Shutdown(ctx)
+		}
+		for i := len(s.stopHooks) - 1; i >= 0; i-- {
+			if err := s.stopHooks[i].OnStop(ctx); err != nil && s.shutdownErr == nil {
+				s.shutdownErr = err
+			}
+		}
+	})
+	return s.shutdownErr
+}
+
+// done is closed once Shutdown has finished
+func (s *Server) done() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdownDone == nil {
+		s.shutdownDone = make(chan struct{})
+	}
+	return s.shutdownDone
+}
+
+func (s *Server) engine() (*gin.Engine, error) {
+	wi := s.interceptor()
+	server := s.newEngine(wi)
+	if s.maxBodyBytes > 0 {
+		server.Use(MaxBodyBytes(int64(s.maxBodyBytes)))
+	}
+	// routes may set a timeout even without web.request_timeout
+	server.Use(wi.RequestTimeout(s.requestTimeout))
+	// lets *gin.Context passed as context.Context follow the request context
+	server.ContextWithFallback = s.requestTimeout > 0
 
 	for _, config := range s.customEngineConfigs {
 		err := config.CustomEngine(server)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	// engine handlers run first for every route
+	engineNames := make([]string, 0, len(server.Handlers))
+	for _, h := range server.Handlers {
+		engineNames = append(engineNames, funcName(h))
+	}
+
+	var routeTable []RouteRecord
+	var routeErrs RouteErrors
+	failed := map[string]bool{}
+	for _, entry := range s.routeEntries() {
+		h, err := wi.intercept(entry.route.Func, entry.route.Options...)
+		if err != nil {
+			// routes of MethodAny fail once for every method
+			if key := entry.path + "\x00" + err.Error(); !failed[key] {
+				failed[key] = true
+				routeErrs = append(routeErrs, fmt.Errorf("%s %s: %w", entry.route.Method, entry.path, err))
+			}
+			continue
 		}
+		info := RouteInfo{
+			Method: entry.method,
+			Path:   entry.path,
+			Base:   entry.handler.Base(),
+			Meta:   entry.route.Meta,
+		}
+		for _, middleware := range entry.route.Middlewares {
+			server.ContextWithFallback = server.ContextWithFallback || isRequestTimeout(middleware)
+		}
+		middlewares, beanNames := s.applyMiddlewares(info)
+		ginHandlers := []gin.HandlerFunc{routeInfoMiddleware(info)}
+		ginHandlers = append(ginHandlers, middlewares...)
+		ginHandlers = append(ginHandlers, entry.route.Middlewares...)
+		ginHandlers = append(ginHandlers, h)
+
+		middlewareNames := append(append([]string{}, engineNames...), funcName(ginHandlers[0]))
+		middlewareNames = append(middlewareNames, beanNames...)
+		for _, middleware := range entry.route.Middlewares {
+			middlewareNames = append(middlewareNames, funcName(middleware))
+		}
+
+		routeTable = append(routeTable, RouteRecord{
+			Method:      entry.method,
+			Path:        entry.path,
+			Handler:     funcName(entry.route.Func),
+			Middlewares: middlewareNames,
+		})
+
+		server.Handle(entry.method, entry.path, ginHandlers...)
+	}
+
+	if len(routeErrs) > 0 {
+		return nil, routeErrs
 	}
 
-	var routePaths []string
+	if s.openAPIPath != "" {
+		doc := s.openAPI(wi)
+		server.GET(s.openAPIPath, func(c *gin.Context) {
+			c.JSON(http.StatusOK, doc)
+		})
+	}
+
+	sort.SliceStable(routeTable, func(i, j int) bool {
+		return routeTable[i].Path < routeTable[j].Path
+	})
+	s.mu.Lock()
+	s.routeTable = routeTable
+	s.mu.Unlock()
+
+	if s.routesPath != "" {
+		server.GET(s.routesPath, func(c *gin.Context) {
+			c.JSON(http.StatusOK, routeTable)
+		})
+	}
+
+	return server, nil
+}
+
+func (s *Server) interceptor() *Interceptor {
+	var options []Options
+	for _, config := range s.interceptorConfigs {
+		options = append(options, config.InterceptorOptions()...)
+	}
+	return NewInterceptor(options...)
+}
+
+// OpenAPI generates an OpenAPI 3.1 document from the routes of every registered Handler,
+// responses and errors are described as the interceptor options of the server write them.
+func (s *Server) OpenAPI() *openapi.Document {
+	return s.openAPI(s.interceptor())
+}
+
+func (s *Server) openAPI(wi *Interceptor) *openapi.Document {
+	info := openapi.Info{
+		Title:   s.openAPITitle,
+		Version: s.openAPIVersion,
+	}
+	return newOpenAPIGenerator(wi).document(info, s.routeEntries())
+}
+
+// ExportOpenAPI writes the OpenAPI document to file as indented JSON.
+func (s *Server) ExportOpenAPI(file string) error {
+	b, err := json.MarshalIndent(s.OpenAPI(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
+
+type routeEntry struct {
+	method  string
+	path    string
+	handler Handler
+	route   Route
+}
+
+func (s *Server) newEngine(wi *Interceptor) *gin.Engine {
+	engine := gin.New()
+	engine.Use(trace.GinMiddleware())
+	switch {
+	case s.accessLog:
+		engine.Use(AccessLog(AccessLogConfig{
+			RequestHeaders: splitList(s.accessLogHeaders),
+			RequestBody:    s.accessLogBody,
+			ResponseBody:   s.accessLogBody,
+			Redact:         splitList(s.accessLogRedact),
+		}))
+	case s.engineMode != "new":
+		engine.Use(gin.Logger())
+	}
+	engine.Use(wi.Recovery())
+	return engine
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func (s *Server) routeEntries() []routeEntry {
+	var entries []routeEntry
 	for _, handler := range s.handlers {
 		rootPath := handler.Base()
 		if !strings.HasPrefix(rootPath, "/") {
 			rootPath = "/" + rootPath
 		}
 
-		for _, route := range handler.Routes() {
-			var routePath string
-			if strings.HasPrefix(route.Path, ".") {
-				routePath = rootPath + route.Path
-			} else {
-				routePath = path.Join(rootPath, route.Path)
-			}
+		entries = s.appendRouteEntries(entries, handler, rootPath, Route{Routes: handler.Routes()})
+	}
+	return entries
+}
 
-			ginHandlers := s.applyMiddlewares(routePath)
-			ginHandlers = append(ginHandlers, wi.Intercept(route.Func))
+// appendRouteEntries flattens groups, their path, options and middlewares are inherited by nested routes
+func (s *Server) appendRouteEntries(entries []routeEntry, handler Handler, basePath string, group Route) []routeEntry {
+	for _, route := range group.Routes {
+		var routePath string
+		if strings.HasPrefix(route.Path, ".") {
+			routePath = basePath + route.Path
+		} else {
+			routePath = path.Join(basePath, route.Path)
+		}
 
-			routePaths = append(routePaths, routePath)
+		route.Options = append(append([]Options{}, group.Options...), route.Options...)
+		route.Middlewares = append(append([]gin.HandlerFunc{}, group.Middlewares...), route.Middlewares...)
+		route.Meta = route.Meta.inherit(group.Meta)
+		if route.isGroup() {
+			entries = s.appendRouteEntries(entries, handler, routePath, route)
+			continue
+		}
 
-			server.Handle(route.Method, routePath, ginHandlers...)
+		for _, method := range route.methods() {
+			entries = append(entries, routeEntry{
+				method:  method,
+				path:    routePath,
+				handler: handler,
+				route:   route,
+			})
 		}
 	}
+	return entries
+}
 
-	sort.Slice(routePaths, func(i, j int) bool {
-		return routePaths[i] < routePaths[j]
-	})
-
-	return server.Run(fmt.Sprintf("%s:%s", s.host, s.port))
+// allMiddlewares adapts every Middleware, a type implementing both interfaces is injected
+// into both lists but only registered once
+func (s *Server) allMiddlewares() []RouteMiddleware {
+	middlewares := make([]RouteMiddleware, 0, len(s.middlewares)+len(s.routeMiddlewares))
+	for _, middleware := range s.middlewares {
+		middlewares = append(middlewares, AdaptMiddleware(middleware))
+	}
+	for _, middleware := range s.routeMiddlewares {
+		if _, ok := middleware.(Middleware); !ok {
+			middlewares = append(middlewares, middleware)
+		}
+	}
+	return middlewares
 }
 
-func (s *Server) applyMiddlewares(path string) []gin.HandlerFunc {
+func (s *Server) applyMiddlewares(route RouteInfo) ([]gin.HandlerFunc, []string) {
 	var handlers []middlewareHandlerWithOrder
-	for _, middleware := range s.middlewares {
-		order := middleware.Register(path)
+	for _, middleware := range s.allMiddlewares() {
+		order := middleware.RegisterRoute(route)
 		if order < 0 {
 			continue
 		}
 		handlers = append(handlers, middlewareHandlerWithOrder{
 			fn:    middleware.Handle,
+			name:  middlewareName(middleware),
 			order: order,
 		})
 	}
@@ -90,11 +522,13 @@ func (s *Server) applyMiddlewares(path string) []gin.HandlerFunc {
 	})
 
 	var ginHandlers []gin.HandlerFunc
+	var names []string
 	for _, handler := range handlers {
 		ginHandlers = append(ginHandlers, handler.fn)
+		names = append(names, handler.name)
 	}
 
-	return ginHandlers
+	return ginHandlers, names
 }
 
 func Run() error {
@@ -105,3 +539,12 @@ func Run() error {
 
 	return server.Run()
 }
+
+func Shutdown(ctx context.Context) error {
+	server, err := ioc.GetObject[Server]("")
+	if err != nil {
+		return err
+	}
+
+	return server.Shutdown(ctx)
+}
@@ -0,0 +1,337 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testHooks struct {
+	calls     []string
+	stopDelay time.Duration
+	stopErr   error
+}
+
+func (h *testHooks) OnStart(_ context.Context) error {
+	h.calls = append(h.calls, "start")
+	return nil
+}
+
+func (h *testHooks) OnStop(_ context.Context) error {
+	time.Sleep(h.stopDelay)
+	h.calls = append(h.calls, "stop")
+	return h.stopErr
+}
+
+func newTestServer(handlers ...Handler) *Server {
+	s := &Server{
+		host:     "127.0.0.1",
+		port:     "0",
+		handlers: handlers,
+	}
+	_ = s.Init()
+	return s
+}
+
+func Test_ServerShutdown(t *testing.T) {
+	hooks := &testHooks{}
+	s := newTestServer(&testUserHandler{})
+	s.startHooks = []ServerStartHook{hooks}
+	s.stopHooks = []ServerStopHook{hooks}
+
+	done := make(chan error)
+	go func() {
+		done <- s.Run()
+	}()
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.httpServer != nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, s.Shutdown(context.Background()))
+	assert.Nil(t, <-done)
+	assert.Equal(t, []string{"start", "stop"}, hooks.calls)
+}
+
+func Test_ServerRunWaitsForShutdown(t *testing.T) {
+	stopErr := errors.New("stop failed")
+	hooks := &testHooks{stopDelay: 50 * time.Millisecond, stopErr: stopErr}
+	s := newTestServer(&testPingHandler{})
+	s.stopHooks = []ServerStopHook{hooks}
+
+	done := make(chan error)
+	go func() {
+		done <- s.Run()
+	}()
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.httpServer != nil
+	}, time.Second, 10*time.Millisecond)
+
+	go func() {
+		_ = s.Shutdown(context.Background())
+	}()
+	assert.ErrorIs(t, <-done, stopErr)
+	assert.Equal(t, []string{"stop"}, hooks.calls)
+}
+
+type testPingHandler struct{}
+
+func (h *testPingHandler) Base() string {
+	return ""
+}
+
+func (h *testPingHandler) Routes() []Route {
+	return Routes(
+		Get("ping", func() string {
+			return "pong"
+		}),
+	)
+}
+
+func Test_ServerServe(t *testing.T) {
+	s := newTestServer(&testPingHandler{})
+	s.readTimeoutStr = "5s"
+	s.maxHeaderBytes = 4096
+	assert.Nil(t, s.Init())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(l)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/ping")
+	assert.Nil(t, err)
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, `"pong"`, string(b))
+
+	s.mu.Lock()
+	assert.Equal(t, 5*time.Second, s.httpServer.ReadTimeout)
+	assert.Equal(t, 4096, s.httpServer.MaxHeaderBytes)
+	s.mu.Unlock()
+
+	assert.Nil(t, s.Shutdown(context.Background()))
+	assert.Nil(t, <-done)
+}
+
+func Test_ServerUnixSocket(t *testing.T) {
+	s := newTestServer(&testPingHandler{})
+	s.unixSocket = filepath.Join(t.TempDir(), "web.sock")
+	done := make(chan error)
+	go func() {
+		done <- s.Run()
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", s.unixSocket)
+		},
+	}}
+	assert.Eventually(t, func() bool {
+		resp, err := client.Get("http://unix/ping")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, s.Shutdown(context.Background()))
+	assert.Nil(t, <-done)
+}
+
+type testFailingHook struct{}
+
+func (testFailingHook) OnStart(_ context.Context) error {
+	return errors.New("start failed")
+}
+
+func Test_ServerStartHookFails(t *testing.T) {
+	first, second := &testHooks{}, &testHooks{}
+	s := newTestServer(&testPingHandler{})
+	s.startHooks = []ServerStartHook{first, second, testFailingHook{}, &testHooks{}}
+
+	assert.EqualError(t, s.Run(), "start failed")
+	assert.Equal(t, []string{"start", "stop"}, first.calls)
+	assert.Equal(t, []string{"start", "stop"}, second.calls)
+}
+
+func Test_ServerShutdownBeforeServe(t *testing.T) {
+	hooks := &testHooks{}
+	s := newTestServer(&testPingHandler{})
+	s.stopHooks = []ServerStopHook{hooks}
+	assert.Nil(t, s.Shutdown(context.Background()))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(l)
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after Shutdown")
+	}
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"stop"}, hooks.calls)
+}
+
+func Test_ServerUnixSocketNotSocket(t *testing.T) {
+	s := newTestServer(&testPingHandler{})
+	s.unixSocket = filepath.Join(t.TempDir(), "web.sock")
+	assert.Nil(t, os.WriteFile(s.unixSocket, []byte("data"), 0o600))
+
+	assert.NotNil(t, s.Run())
+	b, err := os.ReadFile(s.unixSocket)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(b))
+}
+
+func Test_ServerInitInvalidConfig(t *testing.T) {
+	s := &Server{idleTimeoutStr: "forever"}
+	assert.NotNil(t, s.Init())
+	s = &Server{tlsCertFile: "cert.pem"}
+	assert.NotNil(t, s.Init())
+}
+
+type testGroupHandler struct {
+	calls *[]string
+}
+
+func (h *testGroupHandler) Base() string {
+	return "api"
+}
+
+func (h *testGroupHandler) mark(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		*h.calls = append(*h.calls, name)
+	}
+}
+
+func (h *testGroupHandler) Routes() []Route {
+	return Routes(
+		Patch("items/:id", func() {}),
+		Any("echo", func() {}),
+		Group("v1",
+			Get("users", func() {}).Use(h.mark("route")),
+			Group("admin",
+				Delete("users/:id", func() {}),
+			).Use(h.mark("admin")),
+		).Use(h.mark("v1")),
+	)
+}
+
+func Test_RouteGroups(t *testing.T) {
+	var calls []string
+	s := newTestServer(&testGroupHandler{calls: &calls})
+	engine, err := s.engine()
+	assert.Nil(t, err)
+
+	cases := []struct {
+		method string
+		path   string
+		calls  []string
+	}{
+		{http.MethodPatch, "/api/items/1", nil},
+		{http.MethodPut, "/api/echo", nil},
+		{http.MethodHead, "/api/echo", nil},
+		{http.MethodGet, "/api/v1/users", []string{"v1", "route"}},
+		{http.MethodDelete, "/api/v1/admin/users/1", []string{"v1", "admin"}},
+	}
+	for _, cs := range cases {
+		calls = nil
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(cs.method, cs.path, nil))
+		assert.Equal(t, http.StatusNoContent, w.Code, cs.method+" "+cs.path)
+		assert.Equal(t, cs.calls, calls, cs.method+" "+cs.path)
+	}
+}
+
+type testAuthMiddleware struct{}
+
+func (m *testAuthMiddleware) Register(_ string) int {
+	return -1
+}
+
+func (m *testAuthMiddleware) RegisterRoute(route RouteInfo) int {
+	if len(route.Meta.Roles) == 0 {
+		return -1
+	}
+	return 0
+}
+
+func (m *testAuthMiddleware) Handle(c *gin.Context) {
+	route, _ := GetRoute(c)
+	if !route.Meta.HasRole(c.GetHeader("X-Role")) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+type testMetaHandler struct{}
+
+func (h *testMetaHandler) Base() string {
+	return "docs"
+}
+
+func (h *testMetaHandler) Routes() []Route {
+	meta := func(ctx context.Context) RouteMeta {
+		route, _ := GetRoute(ctx)
+		return route.Meta
+	}
+	return Routes(
+		Get("", meta).WithName("listDocs"),
+		Group("",
+			Post("", meta).WithName("createDoc").WithRoles("admin").WithValue("audit", true),
+		).WithTags("write").WithRateLimit("strict").WithValue("scope", "docs"),
+	)
+}
+
+func Test_RouteMeta(t *testing.T) {
+	s := newTestServer(&testMetaHandler{})
+	s.middlewares = []Middleware{&testAuthMiddleware{}}
+	engine, err := s.engine()
+	assert.Nil(t, err)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "listDocs", decode[RouteMeta](t, w).Name)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/docs", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/docs", nil)
+	req.Header.Set("X-Role", "admin")
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, RouteMeta{
+		Name:      "createDoc",
+		Tags:      []string{"write"},
+		Roles:     []string{"admin"},
+		RateLimit: "strict",
+		Values:    map[string]any{"scope": "docs", "audit": true},
+	}, decode[RouteMeta](t, w))
+
+	doc := s.OpenAPI()
+	assert.Equal(t, "createDoc", doc.Paths["/docs"]["post"].OperationID)
+	assert.Equal(t, []string{"write"}, doc.Paths["/docs"]["post"].Tags)
+}
@@ -0,0 +1,284 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sakuradon99/gokit/logger"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+type StreamFormat int
+
+const (
+	StreamSSE StreamFormat = iota
+	StreamNDJSON
+)
+
+// Event is a server-sent event, values of a Stream of SSE format that are not an Event
+// are written as the Data of an unnamed one. Data is written as is if it is a string
+// and as JSON otherwise.
+type Event struct {
+	ID    string
+	Event string
+	Retry time.Duration
+	Data  any
+}
+
+type streamer interface {
+	stream(it *Interceptor, c *gin.Context, status int)
+}
+
+// Stream writes the values produced by Next until it reports no more values, returns an error
+// or the client disconnects, ctx passed to Next is cancelled when the stream stops.
+// Handlers may also return a receive channel, which is written as SSE.
+type Stream[T any] struct {
+	Next      func(ctx context.Context) (T, bool, error)
+	Format    StreamFormat
+	Heartbeat time.Duration
+	Retry     time.Duration
+}
+
+func NewStream[T any](next func(ctx context.Context) (T, bool, error)) Stream[T] {
+	return Stream[T]{Next: next}
+}
+
+// StreamChan streams the values received from ch until it is closed.
+func StreamChan[T any](ch <-chan T) Stream[T] {
+	return NewStream(func(ctx context.Context) (T, bool, error) {
+		select {
+		case v, ok := <-ch:
+			return v, ok, nil
+		case <-ctx.Done():
+			var zero T
+			return zero, false, ctx.Err()
+		}
+	})
+}
+
+func (s Stream[T]) NDJSON() Stream[T] {
+	s.Format = StreamNDJSON
+	return s
+}
+
+// WithHeartbeat writes an SSE comment or an empty NDJSON line when nothing was written for d.
+func (s Stream[T]) WithHeartbeat(d time.Duration) Stream[T] {
+	s.Heartbeat = d
+	return s
+}
+
+// WithRetry tells SSE clients how long to wait before reconnecting.
+func (s Stream[T]) WithRetry(d time.Duration) Stream[T] {
+	s.Retry = d
+	return s
+}
+
+func (s Stream[T]) stream(it *Interceptor, c *gin.Context, status int) {
+	if s.Next == nil {
+		it.handleError(c, errors.New("stream has no Next func"))
+		return
+	}
+	it.writeStream(c, status, s.Format, s.Heartbeat, s.Retry, func(ctx context.Context) (any, bool, error) {
+		return s.Next(ctx)
+	})
+}
+
+type chanStream struct {
+	ch reflect.Value
+}
+
+func (s chanStream) stream(it *Interceptor, c *gin.Context, status int) {
+	it.writeStream(c, status, StreamSSE, 0, 0, func(ctx context.Context) (any, bool, error) {
+		chosen, v, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: s.ch},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		})
+		if chosen == 1 {
+			return nil, false, ctx.Err()
+		}
+		if !ok {
+			return nil, false, nil
+		}
+		return v.Interface(), true, nil
+	})
+}
+
+type streamItem struct {
+	value any
+	ok    bool
+	err   error
+	// stack is set when next panicked
+	stack []byte
+}
+
+// nextItem calls next, recovering a panic as the error of the item since it runs outside
+// of the handler goroutine where Recovery cannot catch it.
+func nextItem(ctx context.Context, next func(ctx context.Context) (any, bool, error)) (item streamItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			item = streamItem{err: fmt.Errorf("panic: %w", err), stack: debug.Stack()}
+		}
+	}()
+	v, ok, err := next(ctx)
+	return streamItem{value: v, ok: ok, err: err}
+}
+
+func (it *Interceptor) writeStream(c *gin.Context, status int, format StreamFormat, heartbeat, retry time.Duration, next func(ctx context.Context) (any, bool, error)) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	if format == StreamNDJSON {
+		c.Header("Content-Type", "application/x-ndjson")
+	} else {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+	}
+	c.Status(status)
+	if format == StreamSSE && retry > 0 {
+		_, _ = fmt.Fprintf(c.Writer, "retry: %d\n\n", retry.Milliseconds())
+	}
+	c.Writer.Flush()
+
+	items := make(chan streamItem)
+	go func() {
+		for {
+			item := nextItem(ctx, next)
+			select {
+			case items <- item:
+			case <-ctx.Done():
+				return
+			}
+			if !item.ok || item.err != nil {
+				return
+			}
+		}
+	}()
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			if format == StreamNDJSON {
+				_, err = c.Writer.WriteString("\n")
+			} else {
+				_, err = c.Writer.WriteString(": ping\n\n")
+			}
+		case item := <-items:
+			if item.stack != nil {
+				writeErrorLog(c, "panic recovered", logger.Field("panic", item.err), logger.Field("stack", string(item.stack)))
+			}
+			if item.err != nil {
+				if ctx.Err() == nil {
+					_ = c.Error(item.err)
+					it.writeStreamError(c, format, item.err)
+				}
+				return
+			}
+			if !item.ok {
+				return
+			}
+			if format == StreamNDJSON {
+				err = writeNDJSON(c, item.value)
+			} else {
+				err = writeEvent(c, item.value)
+			}
+			// the heartbeat is only needed when nothing was written for a while
+			if ticker != nil {
+				ticker.Reset(heartbeat)
+			}
+		}
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// asEvent returns v as an Event if it is one or a non nil *Event
+func asEvent(v any) (Event, bool) {
+	if e, ok := v.(Event); ok {
+		return e, true
+	}
+	if p, ok := v.(*Event); ok && p != nil {
+		return *p, true
+	}
+	return Event{}, false
+}
+
+func writeNDJSON(c *gin.Context, v any) error {
+	if e, ok := asEvent(v); ok {
+		v = e.Data
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = c.Writer.Write(append(b, '\n'))
+	return err
+}
+
+func writeEvent(c *gin.Context, v any) error {
+	e, ok := asEvent(v)
+	if !ok {
+		e = Event{Data: v}
+	}
+
+	data, ok := e.Data.(string)
+	if !ok {
+		b, err := json.Marshal(e.Data)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+
+	sb := &strings.Builder{}
+	if e.ID != "" {
+		sb.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		sb.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		sb.WriteString(fmt.Sprintf("retry: %d\n", e.Retry.Milliseconds()))
+	}
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	_, err := c.Writer.WriteString(sb.String())
+	return err
+}
+
+// writeStreamError reports an error after the headers are sent, so it is written in the stream
+func (it *Interceptor) writeStreamError(c *gin.Context, format StreamFormat, err error) {
+	httpErr := it.mapError(err)
+	if format == StreamNDJSON {
+		_ = writeNDJSON(c, gin.H{"error": httpErr})
+	} else {
+		_ = writeEvent(c, Event{Event: "error", Data: httpErr})
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"github.com/sakuradon99/gokit/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_StreamSSE(t *testing.T) {
+	type progress struct {
+		Done int `json:"done"`
+	}
+
+	f := func() Stream[any] {
+		ch := make(chan any, 3)
+		ch <- Event{ID: "1", Event: "progress", Data: progress{Done: 50}}
+		ch <- "line1\nline2"
+		close(ch)
+		return StreamChan(ch).WithRetry(time.Second)
+	}
+	w, err := serve(http.MethodGet, "/", f, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "retry: 1000\n\n"+
+		"id: 1\nevent: progress\ndata: {\"done\":50}\n\n"+
+		"data: line1\ndata: line2\n\n", w.Body.String())
+
+	ch := make(chan int, 2)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	w, err = serve(http.MethodGet, "/", func() <-chan int { return ch }, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "data: 1\n\ndata: 2\n\n", w.Body.String())
+}
+
+func Test_StreamNDJSON(t *testing.T) {
+	n := 0
+	next := func(ctx context.Context) (int, bool, error) {
+		n++
+		if n == 3 {
+			return 0, false, errors.New("boom")
+		}
+		return n, true, nil
+	}
+	w, err := serve(http.MethodGet, "/", func() Stream[int] { return NewStream(next).NDJSON() },
+		httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1\n2\n{\"error\":{\"code\":500,\"message\":\"internal server error\"}}\n", w.Body.String())
+}
+
+func Test_StreamDisconnect(t *testing.T) {
+	stopped := make(chan struct{})
+	next := func(ctx context.Context) (string, bool, error) {
+		<-ctx.Done()
+		close(stopped)
+		return "", false, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	w, err := serve(http.MethodGet, "/", func() Stream[string] {
+		return NewStream(next).WithHeartbeat(10 * time.Millisecond)
+	}, req)
+	assert.Nil(t, err)
+	assert.Contains(t, w.Body.String(), ": ping\n\n")
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stream producer not stopped")
+	}
+}
+
+func Test_StreamPanic(t *testing.T) {
+	var logged []string
+	defer func(f func(ctx context.Context, msg string, fields ...logger.LogField)) { writeErrorLog = f }(writeErrorLog)
+	writeErrorLog = func(ctx context.Context, msg string, fields ...logger.LogField) {
+		logged = append(logged, msg)
+	}
+
+	next := func(ctx context.Context) (int, bool, error) {
+		panic("boom")
+	}
+	w, err := serve(http.MethodGet, "/", func() Stream[int] { return NewStream(next).NDJSON() },
+		httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualError(t, err, "panic: boom")
+	assert.Equal(t, "{\"error\":{\"code\":500,\"message\":\"internal server error\"}}\n", w.Body.String())
+	assert.Equal(t, []string{"panic recovered"}, logged)
+
+	w, err = serve(http.MethodGet, "/", func() Stream[int] { return Stream[int]{} },
+		httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualError(t, err, "stream has no Next func")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func Test_StreamHeartbeatAfterIdle(t *testing.T) {
+	n := 0
+	next := func(ctx context.Context) (int, bool, error) {
+		n++
+		if n > 5 {
+			return 0, false, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+		return n, true, nil
+	}
+	w, err := serve(http.MethodGet, "/", func() Stream[int] {
+		return NewStream(next).WithHeartbeat(30 * time.Millisecond)
+	}, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.NotContains(t, w.Body.String(), ": ping")
+}
+
+func Test_StreamNDJSONEvents(t *testing.T) {
+	ch := make(chan any, 3)
+	ch <- Event{ID: "1", Event: "progress", Data: 1}
+	ch <- &Event{ID: "2", Data: "two"}
+	ch <- 3
+	close(ch)
+	w, err := serve(http.MethodGet, "/", func() Stream[any] { return StreamChan(ch).NDJSON() },
+		httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n\"two\"\n3\n", w.Body.String())
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const keyFiles = "web_files"
+
+var (
+	typeFileHeader    = reflect.TypeOf((*multipart.FileHeader)(nil))
+	typeMultipartFile = reflect.TypeOf((*multipart.File)(nil)).Elem()
+	typeBytes         = reflect.TypeOf([]byte(nil))
+)
+
+// fileBinder binds the uploads of the file or files tag to *multipart.FileHeader, []byte or
+// an opened reader such as multipart.File or io.Reader, and slices of them for files.
+// Opened readers are closed once the handler returns. The uploads are checked against
+// the maxsize (e.g. 10MB), mime (sniffed from the content, e.g. image/*) and maxcount tags,
+// and are required unless tagged optional:"true".
+type fileBinder struct {
+	field    int
+	name     string
+	multiple bool
+	elem     reflect.Type
+	maxSize  int64
+	sizeTag  string
+	maxCount int
+	mimes    []string
+	optional bool
+}
+
+func newFileBinder(i int, field reflect.StructField, name string) (*fileBinder, error) {
+	b := &fileBinder{
+		field:    i,
+		name:     name,
+		multiple: field.Tag.Get("files") != "",
+		elem:     field.Type,
+		optional: field.Tag.Get("optional") == "true",
+	}
+	if b.multiple {
+		if field.Type.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("unsupported type %s for files", field.Type)
+		}
+		b.elem = field.Type.Elem()
+	}
+	if b.elem != typeFileHeader && b.elem != typeBytes &&
+		!(b.elem.Kind() == reflect.Interface && typeMultipartFile.Implements(b.elem)) {
+		return nil, fmt.Errorf("unsupported type %s for file", field.Type)
+	}
+
+	if s := field.Tag.Get("maxsize"); s != "" {
+		size, err := parseSize(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxsize %q: %w", s, err)
+		}
+		b.maxSize = size
+		b.sizeTag = s
+	}
+	if s := field.Tag.Get("maxcount"); s != "" {
+		count, err := strconv.Atoi(s)
+		if err != nil || !b.multiple {
+			return nil, fmt.Errorf("invalid maxcount %q", s)
+		}
+		b.maxCount = count
+	}
+	if s := field.Tag.Get("mime"); s != "" {
+		for _, m := range strings.Split(s, ",") {
+			b.mimes = append(b.mimes, strings.TrimSpace(m))
+		}
+	}
+	return b, nil
+}
+
+func (b *fileBinder) Bind(c *gin.Context, param reflect.Value) error {
+	form, err := c.MultipartForm()
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return fileError(b.name, err)
+	}
+	var headers []*multipart.FileHeader
+	if form != nil {
+		headers = form.File[b.name]
+	}
+	if len(headers) == 0 {
+		if b.optional {
+			return nil
+		}
+		return fileError(b.name, http.ErrMissingFile)
+	}
+	if !b.multiple {
+		headers = headers[:1]
+	}
+	if b.maxCount > 0 && len(headers) > b.maxCount {
+		return b.error(fmt.Sprintf("maxcount=%d", b.maxCount), fmt.Errorf("%d files uploaded", len(headers)))
+	}
+
+	values := reflect.MakeSlice(reflect.SliceOf(b.elem), 0, len(headers))
+	for _, header := range headers {
+		v, err := b.bindFile(c, header)
+		if err != nil {
+			return err
+		}
+		values = reflect.Append(values, v)
+	}
+	if b.multiple {
+		param.Field(b.field).Set(values)
+	} else {
+		param.Field(b.field).Set(values.Index(0))
+	}
+	return nil
+}
+
+func (b *fileBinder) bindFile(c *gin.Context, header *multipart.FileHeader) (reflect.Value, error) {
+	if b.maxSize > 0 && header.Size > b.maxSize {
+		return reflect.Value{}, b.error("maxsize="+b.sizeTag,
+			fmt.Errorf("%s has %d bytes", header.Filename, header.Size))
+	}
+
+	if len(b.mimes) > 0 {
+		contentType, err := sniffContentType(header)
+		if err != nil {
+			return reflect.Value{}, fileError(b.name, err)
+		}
+		if !matchMime(b.mimes, contentType) {
+			return reflect.Value{}, b.error("mime="+strings.Join(b.mimes, ","),
+				fmt.Errorf("%s has content type %s", header.Filename, contentType))
+		}
+	}
+
+	switch b.elem {
+	case typeFileHeader:
+		return reflect.ValueOf(header), nil
+	case typeBytes:
+		file, err := header.Open()
+		if err != nil {
+			return reflect.Value{}, fileError(b.name, err)
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return reflect.Value{}, fileError(b.name, err)
+		}
+		return reflect.ValueOf(data), nil
+	default:
+		file, err := header.Open()
+		if err != nil {
+			return reflect.Value{}, fileError(b.name, err)
+		}
+		files, _ := c.Value(keyFiles).([]io.Closer)
+		c.Set(keyFiles, append(files, file))
+		return reflect.ValueOf(file).Convert(b.elem), nil
+	}
+}
+
+func (b *fileBinder) error(tag string, err error) error {
+	return &BindError{Source: SourceFile, Field: b.name, Reason: ReasonValidate, Tag: tag, Err: err}
+}
+
+func fileError(name string, err error) error {
+	reason := ReasonParse
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		reason = ReasonRequired
+	}
+	return &BindError{Source: SourceFile, Field: name, Reason: reason, Err: err}
+}
+
+// closeFiles closes the uploads opened for the handler
+func closeFiles(c *gin.Context) {
+	files, _ := c.Value(keyFiles).([]io.Closer)
+	if len(files) == 0 {
+		return
+	}
+	for _, file := range files {
+		_ = file.Close()
+	}
+	c.Set(keyFiles, nil)
+}
+
+func sniffContentType(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+func matchMime(patterns []string, contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	for _, pattern := range patterns {
+		if pattern == contentType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseSize parses sizes such as 512, 512B, 10KB or 10M
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * unit, nil
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newUploadRequest(t *testing.T, files map[string][][]byte) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for name, contents := range files {
+		for _, content := range contents {
+			w, err := mw.CreateFormFile(name, name+".bin")
+			assert.Nil(t, err)
+			_, _ = w.Write(content)
+		}
+	}
+	assert.Nil(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func Test_FileBinder(t *testing.T) {
+	type params struct {
+		Avatar []byte                  `file:"avatar" maxsize:"1KB" mime:"image/*"`
+		Docs   []multipart.File        `files:"docs" maxcount:"2"`
+		Extra  *multipart.FileHeader   `file:"extra" optional:"true"`
+		Others []*multipart.FileHeader `files:"others" optional:"true"`
+	}
+	type result struct {
+		Avatar int
+		Docs   []string
+		Extra  bool
+	}
+	f := func(p params) (result, error) {
+		r := result{Avatar: len(p.Avatar), Extra: p.Extra != nil}
+		for _, doc := range p.Docs {
+			b, err := io.ReadAll(doc)
+			if err != nil {
+				return r, err
+			}
+			r.Docs = append(r.Docs, string(b))
+		}
+		return r, nil
+	}
+
+	w, err := serve(http.MethodPost, "/", f, newUploadRequest(t, map[string][][]byte{
+		"avatar": {testPNG},
+		"docs":   {[]byte("a"), []byte("b")},
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, result{Avatar: len(testPNG), Docs: []string{"a", "b"}}, decode[result](t, w))
+
+	_, err = serve(http.MethodPost, "/", f, newUploadRequest(t, map[string][][]byte{
+		"avatar": {[]byte("plain text")},
+		"docs":   {[]byte("a"), []byte("b"), []byte("c")},
+	}))
+	assert.Equal(t, BindErrors{
+		{Source: SourceFile, Field: "avatar", Reason: ReasonValidate, Tag: "mime=image/*"},
+		{Source: SourceFile, Field: "docs", Reason: ReasonValidate, Tag: "maxcount=2"},
+	}, clearErrs(err.(BindErrors)))
+
+	_, err = serve(http.MethodPost, "/", f, newUploadRequest(t, map[string][][]byte{
+		"avatar": {append(testPNG, make([]byte, 1024)...)},
+	}))
+	assert.Equal(t, BindErrors{
+		{Source: SourceFile, Field: "avatar", Reason: ReasonValidate, Tag: "maxsize=1KB"},
+		{Source: SourceFile, Field: "docs", Reason: ReasonRequired},
+	}, clearErrs(err.(BindErrors)))
+
+	_, err = NewInterceptor().intercept(func(p struct {
+		Docs multipart.File `files:"docs"`
+	}) {
+	})
+	assert.NotNil(t, err)
+}
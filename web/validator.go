@@ -1,5 +1,9 @@
 package web
 
+import "reflect"
+
+var typeValidator = reflect.TypeOf((*Validator)(nil)).Elem()
+
 type Validator interface {
 	Validate() error
 }
@@ -1,46 +1,118 @@
 package web
 
 import (
+	"encoding"
+	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
-func setVal(fieldValue reflect.Value, val string) error {
-	switch fieldValue.Interface().(type) {
-	case string:
+var (
+	typeTime            = reflect.TypeOf(time.Time{})
+	typeDuration        = reflect.TypeOf(time.Duration(0))
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func checkValType(rtp reflect.Type) error {
+	if rtp.Kind() == reflect.Ptr {
+		rtp = rtp.Elem()
+	}
+	if rtp == typeTime || reflect.PtrTo(rtp).Implements(typeTextUnmarshaler) {
+		return nil
+	}
+	switch rtp.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", rtp)
+}
+
+func setVal(fieldValue reflect.Value, val string, layout string) error {
+	if fieldValue.Kind() == reflect.Ptr {
+		if val == "" {
+			return nil
+		}
+		p := reflect.New(fieldValue.Type().Elem())
+		if err := setVal(p.Elem(), val, layout); err != nil {
+			return err
+		}
+		fieldValue.Set(p)
+		return nil
+	}
+	if val == "" && zeroOnEmpty(fieldValue.Type()) {
+		return nil
+	}
+
+	if fieldValue.Type() == typeTime {
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return err
+		}
+		fieldValue.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if fieldValue.Type() == typeDuration {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fieldValue.SetInt(int64(d))
+		return nil
+	}
+	if u, ok := fieldValue.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(val))
+	}
+
+	switch fieldValue.Kind() {
+	case reflect.String:
 		fieldValue.SetString(val)
-	case int64, int32, int16, int8, int:
-		int64Val, err := strconv.ParseInt(val, 10, 64)
+	case reflect.Bool:
+		boolVal, err := strconv.ParseBool(val)
 		if err != nil {
-			return ErrInvalidParams
+			return err
+		}
+		fieldValue.SetBool(boolVal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		int64Val, err := strconv.ParseInt(val, 10, fieldValue.Type().Bits())
+		if err != nil {
+			return err
 		}
 		fieldValue.SetInt(int64Val)
-	case uint64, uint32, uint16, uint8, uint:
-		uint64Val, err := strconv.ParseUint(val, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uint64Val, err := strconv.ParseUint(val, 10, fieldValue.Type().Bits())
 		if err != nil {
-			return ErrInvalidParams
+			return err
 		}
 		fieldValue.SetUint(uint64Val)
-	case *string:
-		if val != "" {
-			p := reflect.New(fieldValue.Type().Elem())
-			p.Elem().SetString(val)
-			fieldValue.Set(p)
-		}
-	case *int64, *int32, *int16, *int8, *int:
-		int64Val, err := strconv.ParseInt(val, 10, 64)
-		if err == nil {
-			p := reflect.New(fieldValue.Type().Elem())
-			p.Elem().SetInt(int64Val)
-			fieldValue.Set(p)
-		}
-	case *uint64, *uint32, *uint16, *uint8, *uint:
-		uint64Val, err := strconv.ParseUint(val, 10, 64)
-		if err == nil {
-			p := reflect.New(fieldValue.Type().Elem())
-			p.Elem().SetUint(uint64Val)
-			fieldValue.Set(p)
+	case reflect.Float32, reflect.Float64:
+		float64Val, err := strconv.ParseFloat(val, fieldValue.Type().Bits())
+		if err != nil {
+			return err
 		}
+		fieldValue.SetFloat(float64Val)
+	default:
+		return fmt.Errorf("unsupported type %s", fieldValue.Type())
 	}
 	return nil
 }
+
+// zeroOnEmpty reports the types left to their zero value when the value is missing,
+// validate:"required" enforces their presence. TextUnmarshaler is not called for missing
+// values. Integers keep failing as they always did.
+func zeroOnEmpty(rtp reflect.Type) bool {
+	if rtp == typeTime || rtp == typeDuration || reflect.PtrTo(rtp).Implements(typeTextUnmarshaler) {
+		return true
+	}
+	switch rtp.Kind() {
+	case reflect.Bool, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var typeConn = reflect.TypeOf((*Conn)(nil))
+
+type WebSocketConfig struct {
+	Subprotocols []string
+	CheckOrigin  func(r *http.Request) bool
+	// PingInterval is how often pings are sent, the connection is closed when no pong
+	// or message arrives within PongWait.
+	PingInterval time.Duration
+	PongWait     time.Duration
+	WriteWait    time.Duration
+	ReadLimit    int64
+}
+
+func (cfg WebSocketConfig) withDefaults() WebSocketConfig {
+	if cfg.PongWait <= 0 {
+		cfg.PongWait = 60 * time.Second
+	}
+	if cfg.PingInterval <= 0 || cfg.PingInterval >= cfg.PongWait {
+		cfg.PingInterval = cfg.PongWait * 9 / 10
+	}
+	if cfg.WriteWait <= 0 {
+		cfg.WriteWait = 10 * time.Second
+	}
+	return cfg
+}
+
+// Conn is the connection passed to WebSocket handlers, reads and writes are safe for
+// concurrent use. Messages are read by an internal pump so pongs and close frames are
+// handled while the handler only writes, a message that is not read holds the pump back.
+type Conn struct {
+	conn   *websocket.Conn
+	cfg    WebSocketConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	closed bool
+
+	msgs    chan wsMessage
+	readErr error
+}
+
+type wsMessage struct {
+	messageType int
+	data        []byte
+}
+
+// Context is done when the connection is closed or the keepalive fails.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *Conn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+func (c *Conn) ReadJSON(v any) error {
+	_, b, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ReadMessage returns the next message, or the error that stopped the read pump.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	msg, ok := <-c.msgs
+	if !ok {
+		return 0, nil, c.readErr
+	}
+	return msg.messageType, msg.data, nil
+}
+
+func (c *Conn) WriteJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+	return c.conn.WriteJSON(v)
+}
+
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteWait))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// Close sends a close message with code and reason then closes the connection,
+// it does nothing if the connection is already closed.
+func (c *Conn) Close(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.cancel()
+
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	msg := websocket.FormatCloseMessage(code, reason)
+	err := c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(c.cfg.WriteWait))
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readPump reads until the connection fails or is closed, readErr is set before msgs
+// is closed.
+func (c *Conn) readPump() {
+	defer close(c.msgs)
+	for {
+		t, b, err := c.conn.ReadMessage()
+		if err != nil {
+			c.readErr = err
+			c.cancel()
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
+		select {
+		case c.msgs <- wsMessage{messageType: t, data: b}:
+		case <-c.ctx.Done():
+			c.readErr = net.ErrClosed
+			return
+		}
+	}
+}
+
+func (c *Conn) keepalive() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.WriteWait))
+			if err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (it *Interceptor) upgrade(c *gin.Context) (*Conn, error) {
+	cfg := it.webSocket.withDefaults()
+	upgrader := websocket.Upgrader{
+		Subprotocols: cfg.Subprotocols,
+		CheckOrigin:  cfg.CheckOrigin,
+	}
+	// Upgrade writes the http error itself
+	wc, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ReadLimit > 0 {
+		wc.SetReadLimit(cfg.ReadLimit)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := &Conn{
+		conn:   wc,
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		msgs:   make(chan wsMessage),
+	}
+	_ = wc.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	wc.SetPongHandler(func(string) error {
+		return wc.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	})
+	go conn.readPump()
+	go conn.keepalive()
+	return conn, nil
+}
+
+// closeWebSocket ends the connection after the handler returned, errors caused by
+// the peer closing the connection are not reported.
+func (it *Interceptor) closeWebSocket(c *gin.Context, conn *Conn, err error) {
+	if err == nil || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		_ = conn.Close(websocket.CloseNormalClosure, "")
+		return
+	}
+	_ = c.Error(err)
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		_ = conn.Close(websocket.CloseNormalClosure, "")
+		return
+	}
+	_ = conn.Close(websocket.CloseInternalServerErr, it.mapError(err).Message)
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_WebSocket(t *testing.T) {
+	type params struct {
+		Room string `path:"room"`
+		User string `query:"user" validate:"required"`
+	}
+	type message struct {
+		User string `json:"user"`
+		Text string `json:"text"`
+	}
+
+	it := NewInterceptor()
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("middleware", true)
+	})
+	engine.GET("/rooms/:room", it.Intercept(func(c *gin.Context, p params, conn *Conn) error {
+		assert.True(t, c.GetBool("middleware"))
+		for {
+			var msg message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return err
+			}
+			if msg.Text == "fail" {
+				return errors.New("boom")
+			}
+			if err := conn.WriteJSON(message{User: p.User, Text: p.Room + ":" + msg.Text}); err != nil {
+				return err
+			}
+		}
+	}))
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rooms/r1"
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?user=u1", nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	assert.Nil(t, conn.WriteJSON(message{Text: "hi"}))
+	var msg message
+	assert.Nil(t, conn.ReadJSON(&msg))
+	assert.Equal(t, message{User: "u1", Text: "r1:hi"}, msg)
+
+	assert.Nil(t, conn.WriteJSON(message{Text: "fail"}))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseInternalServerErr))
+	assert.Contains(t, err.Error(), "internal server error")
+}
+
+func Test_WebSocketWriteOnly(t *testing.T) {
+	done := make(chan struct{})
+	it := NewInterceptor()
+	engine := gin.New()
+	engine.GET("/events", it.Intercept(func(conn *Conn) error {
+		defer close(done)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+			return err
+		}
+		<-conn.Context().Done()
+		return nil
+	}))
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events", nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	pong := make(chan struct{}, 1)
+	conn.SetPongHandler(func(string) error {
+		pong <- struct{}{}
+		return nil
+	})
+	_, b, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(b))
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	assert.Nil(t, conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)))
+	select {
+	case <-pong:
+	case <-time.After(time.Second):
+		t.Fatal("no pong while the handler only writes")
+	}
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	assert.Nil(t, conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler context not done after the peer closed")
+	}
+}